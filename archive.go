@@ -0,0 +1,316 @@
+package nbastats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// metadataFile is the record metadata key containing the archived file a
+// record was read from.
+const metadataFile = "nbastats.file"
+
+// Orders in which archived files are processed.
+const (
+	archiveOrderName = "name"
+	archiveOrderDate = "date"
+)
+
+// fileDate matches dates embedded in file names, e.g. 2024-01-31,
+// 2024_01_31 or 20240131.
+var fileDate = regexp.MustCompile(`(\d{4})[-_]?(\d{2})[-_]?(\d{2})`)
+
+// ArchiveConfig configures reading archived stats.nba.com responses instead
+// of querying the API.
+type ArchiveConfig struct {
+	// Path is a directory containing archived responses as .json files, or a
+	// glob pattern matching them.
+	Path string `json:"path"`
+	// Order in which the files are processed: "name" orders them by file
+	// name, "date" by the date embedded in the file name (e.g. 2024-01-31 or
+	// 20240131), files without a date come last.
+	Order string `json:"order" validate:"inclusion=name|date" default:"name"`
+}
+
+func (c ArchiveConfig) validate() error {
+	if c.Path == "" {
+		return errors.New("archive mode requires an archive path")
+	}
+	if _, err := filepath.Glob(c.Path); err != nil {
+		return fmt.Errorf("invalid archive path: %w", err)
+	}
+	switch c.Order {
+	case "", archiveOrderName, archiveOrderDate:
+		return nil
+	default:
+		return fmt.Errorf("unsupported archive order %q", c.Order)
+	}
+}
+
+// archivePosition is the position of a record read from an archived file.
+// Row is only set with format rows, with format response every file is a
+// single record.
+type archivePosition struct {
+	File string `json:"file"`
+	Row  *int   `json:"row,omitempty"`
+}
+
+func parseArchivePosition(pos sdk.Position) (archivePosition, error) {
+	var p archivePosition
+	if len(pos) == 0 {
+		return p, nil
+	}
+	err := json.Unmarshal(pos, &p)
+	if err != nil {
+		return p, fmt.Errorf("invalid archive position %q: %w", pos, err)
+	}
+	return p, nil
+}
+
+func (p archivePosition) toSDKPosition() sdk.Position {
+	raw, _ := json.Marshal(p)
+	return raw
+}
+
+// archiveFile is an archived response, the key it is ordered by and the date
+// embedded in its name, if any.
+type archiveFile struct {
+	path string
+	name string
+	date time.Time
+}
+
+// archiveReader reads archived responses in order and builds the same
+// records as the source builds from live responses. Files are processed
+// once, files added later are picked up by the next scan if they are
+// ordered after the last processed file.
+type archiveReader struct {
+	config     ArchiveConfig
+	format     string
+	dimensions *dimensionCache
+
+	// last is the last file that was opened and skip the number of its rows
+	// that were already read before the source was restarted.
+	last   *archiveFile
+	skip   int
+	files  []archiveFile
+	stream *rowStream
+}
+
+func newArchiveReader(config ArchiveConfig, format string, dimensions *dimensionCache, pos sdk.Position) (*archiveReader, error) {
+	p, err := parseArchivePosition(pos)
+	if err != nil {
+		return nil, err
+	}
+	a := &archiveReader{config: config, format: format, dimensions: dimensions}
+	if p.File == "" {
+		return a, nil
+	}
+
+	last := a.file(p.File)
+	a.last = &last
+	if p.Row != nil {
+		// continue reading the file after the last row
+		a.files = []archiveFile{last}
+		a.skip = *p.Row + 1
+	}
+	return a, nil
+}
+
+// scan adds the files ordered after the last processed file and returns the
+// number of files added.
+func (a *archiveReader) scan() (int, error) {
+	paths, err := a.paths()
+	if err != nil {
+		return 0, err
+	}
+	queued := make(map[string]bool, len(a.files))
+	for _, f := range a.files {
+		queued[f.path] = true
+	}
+
+	var files []archiveFile
+	for _, path := range paths {
+		f := a.file(path)
+		if queued[path] || (a.last != nil && !a.less(*a.last, f)) {
+			continue
+		}
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return a.less(files[i], files[j]) })
+	a.files = append(a.files, files...)
+	return len(files), nil
+}
+
+// next returns the next record, or io.EOF once all scanned files were read.
+func (a *archiveReader) next(ctx context.Context) (sdk.Record, error) {
+	for {
+		if a.stream != nil {
+			rec, err := a.stream.next(ctx)
+			if err == nil {
+				row := a.stream.index - 1
+				if row < a.skip {
+					continue
+				}
+				a.tag(&rec, *a.last, &row, a.stream.dec.parameters)
+				return rec, nil
+			}
+			a.closeStream(ctx)
+			if !errors.Is(err, io.EOF) {
+				return sdk.Record{}, fmt.Errorf("failed to read %q: %w", a.last.path, err)
+			}
+			continue
+		}
+		if len(a.files) == 0 {
+			return sdk.Record{}, io.EOF
+		}
+
+		f := a.files[0]
+		a.files = a.files[1:]
+		if a.last == nil || a.last.path != f.path {
+			a.skip = 0
+		}
+		a.last = &f
+		sdk.Logger(ctx).Info().Str("file", f.path).Msg("reading archived response")
+
+		if a.format == formatRows {
+			body, err := os.Open(f.path)
+			if err != nil {
+				return sdk.Record{}, fmt.Errorf("failed to open archived response: %w", err)
+			}
+			a.stream = newRowStream(f.path, body, a.dimensions, "", "", "", 0)
+			continue
+		}
+		return a.responseRecord(ctx, f)
+	}
+}
+
+// responseRecord returns the whole archived response as a single raw record.
+func (a *archiveReader) responseRecord(ctx context.Context, f archiveFile) (sdk.Record, error) {
+	body, err := os.ReadFile(f.path)
+	if err != nil {
+		return sdk.Record{}, fmt.Errorf("failed to read archived response: %w", err)
+	}
+	var data ResponseData
+	err = json.Unmarshal(body, &data)
+	if err != nil {
+		return sdk.Record{}, fmt.Errorf("failed to read %q: %w", f.path, &requestError{kind: errDecode, URL: f.path, err: err})
+	}
+	if a.dimensions != nil {
+		body, err = a.dimensions.enrichBody(ctx, body)
+		if err != nil {
+			return sdk.Record{}, err
+		}
+	}
+
+	parameters, _ := data.Parameters.(map[string]interface{})
+	rec := sdk.Util.Source.NewRecordCreate(
+		nil,
+		sdk.Metadata{metadataWindow: windowFromParameters(parameters)},
+		sdk.RawData(f.name),
+		sdk.RawData(body),
+	)
+	a.tag(&rec, f, nil, parameters)
+	return rec, nil
+}
+
+// tag sets the position of a record read from an archived file and adds the
+// file, the date embedded in its name and the query parameters the response
+// was requested with to its metadata.
+func (a *archiveReader) tag(rec *sdk.Record, f archiveFile, row *int, parameters map[string]interface{}) {
+	rec.Position = archivePosition{File: f.path, Row: row}.toSDKPosition()
+	rec.Metadata[metadataFile] = f.path
+	if row != nil {
+		// the rows of every result set in a file form one snapshot
+		rec.Metadata[metadataSnapshot] = f.path + "_" + rec.Metadata[metadataCollection]
+	}
+	if !f.date.IsZero() {
+		rec.Metadata[metadataDate] = f.date.Format("2006-01-02")
+	}
+	for key, param := range map[string]string{
+		metadataSeason:      "Season",
+		metadataSeasonType:  "SeasonType",
+		metadataMeasureType: "PtMeasureType",
+	} {
+		if v, ok := parameters[param].(string); ok && v != "" {
+			rec.Metadata[key] = v
+		}
+	}
+	if id, ok := parameters["LeagueID"].(string); ok {
+		for name, l := range leagues {
+			if l.ID == id {
+				rec.Metadata[metadataLeague] = name
+			}
+		}
+	}
+}
+
+func (a *archiveReader) closeStream(ctx context.Context) {
+	if a.stream == nil {
+		return
+	}
+	err := a.stream.close()
+	if err != nil {
+		sdk.Logger(ctx).Warn().Err(err).Msg("failed to close archived response")
+	}
+	a.stream = nil
+}
+
+// paths returns the paths of all archived files.
+func (a *archiveReader) paths() ([]string, error) {
+	pattern := a.config.Path
+	if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+		pattern = filepath.Join(pattern, "*.json")
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive path: %w", err)
+	}
+	paths := matches[:0]
+	for _, m := range matches {
+		if info, err := os.Stat(m); err == nil && !info.IsDir() {
+			paths = append(paths, m)
+		}
+	}
+	return paths, nil
+}
+
+func (a *archiveReader) file(path string) archiveFile {
+	f := archiveFile{path: path, name: filepath.Base(path)}
+	for _, m := range fileDate.FindAllStringSubmatch(f.name, -1) {
+		date, err := time.Parse("20060102", m[1]+m[2]+m[3])
+		if err == nil {
+			f.date = date
+			break
+		}
+	}
+	return f
+}
+
+// less reports whether file x is processed before file y.
+func (a *archiveReader) less(x, y archiveFile) bool {
+	if a.config.Order == archiveOrderDate && !x.date.Equal(y.date) {
+		switch {
+		case x.date.IsZero():
+			return false
+		case y.date.IsZero():
+			return true
+		default:
+			return x.date.Before(y.date)
+		}
+	}
+	if x.name != y.name {
+		return x.name < y.name
+	}
+	return strings.Compare(x.path, y.path) < 0
+}
@@ -0,0 +1,151 @@
+package nbastats
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// errCircuitOpen is returned without sending a request while the circuit
+// breaker is open.
+var errCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitBreakerConfig configures the circuit breaker that stops requests
+// to stats.nba.com while it keeps failing.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed requests after
+	// which the circuit opens. 0 disables the circuit breaker.
+	FailureThreshold int `json:"failureThreshold" default:"5"`
+	// OpenDuration is how long the circuit stays open before probe requests
+	// are let through.
+	OpenDuration time.Duration `json:"openDuration" default:"1m"`
+	// HalfOpenProbes is the number of probe requests let through after the
+	// circuit was open, all of them have to succeed to close it again.
+	HalfOpenProbes int `json:"halfOpenProbes" default:"1"`
+}
+
+// Circuit breaker states.
+const (
+	circuitClosed   = "closed"
+	circuitOpen     = "open"
+	circuitHalfOpen = "half-open"
+)
+
+// circuitBreaker tracks the outcome of requests and rejects requests while
+// stats.nba.com is considered unavailable. Only failures that are expected
+// to go away on their own (see isRetryable) count towards the threshold,
+// a bad request or an undecodable response doesn't mean the API is down.
+type circuitBreaker struct {
+	config CircuitBreakerConfig
+
+	m         sync.Mutex
+	state     string
+	failures  int
+	openedAt  time.Time
+	probes    int
+	successes int
+
+	// opened counts how often the closed circuit opened, rejected the
+	// requests rejected since it was last closed. Both are logged on every
+	// state change.
+	opened   int
+	rejected int
+}
+
+func newCircuitBreaker(config CircuitBreakerConfig) *circuitBreaker {
+	if config.FailureThreshold <= 0 {
+		return nil
+	}
+	if config.HalfOpenProbes <= 0 {
+		config.HalfOpenProbes = 1
+	}
+	return &circuitBreaker{config: config, state: circuitClosed}
+}
+
+// allow returns an error if the request to url must not be sent. Every
+// allowed request has to be reported with done.
+func (b *circuitBreaker) allow(ctx context.Context, url string) error {
+	if b == nil {
+		return nil
+	}
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	if b.state == circuitOpen && time.Since(b.openedAt) >= b.config.OpenDuration {
+		b.transition(ctx, circuitHalfOpen)
+	}
+	switch {
+	case b.state == circuitOpen,
+		b.state == circuitHalfOpen && b.probes >= b.config.HalfOpenProbes:
+		b.rejected++
+		return &requestError{kind: errCircuitOpen, URL: url}
+	case b.state == circuitHalfOpen:
+		b.probes++
+	}
+	return nil
+}
+
+// done records the outcome of an allowed request. Cancelled requests don't
+// count as failure or success, but free their probe slot.
+func (b *circuitBreaker) done(ctx context.Context, err error) {
+	if b == nil {
+		return
+	}
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	if errors.Is(err, context.Canceled) {
+		if b.state == circuitHalfOpen && b.probes > 0 {
+			b.probes--
+		}
+		return
+	}
+
+	if err != nil && isRetryable(err) {
+		b.failures++
+		if b.state == circuitHalfOpen || b.failures >= b.config.FailureThreshold {
+			sdk.Logger(ctx).Warn().Err(err).
+				Int("failures", b.failures).
+				Dur("open_duration", b.config.OpenDuration).
+				Msg("stats.nba.com keeps failing, pausing requests")
+			b.openedAt = time.Now()
+			b.transition(ctx, circuitOpen)
+		}
+		return
+	}
+
+	b.failures = 0
+	if b.state == circuitHalfOpen {
+		b.successes++
+		if b.successes >= b.config.HalfOpenProbes {
+			b.transition(ctx, circuitClosed)
+		}
+	}
+}
+
+// transition changes the state of the circuit, the caller has to hold the
+// lock.
+func (b *circuitBreaker) transition(ctx context.Context, state string) {
+	if b.state == state {
+		return
+	}
+	if state == circuitOpen && b.state == circuitClosed {
+		b.opened++
+		b.rejected = 0
+	}
+	sdk.Logger(ctx).Info().
+		Str("from", b.state).
+		Str("to", state).
+		Int("opened", b.opened).
+		Int("rejected", b.rejected).
+		Msg("circuit breaker state changed")
+
+	if state == circuitHalfOpen {
+		b.probes = 0
+		b.successes = 0
+	}
+	b.state = state
+}
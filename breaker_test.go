@@ -0,0 +1,208 @@
+package nbastats
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/rs/zerolog"
+)
+
+var (
+	errTestUpstream   = &requestError{kind: errUpstream, URL: "test"}
+	errTestBadRequest = &requestError{kind: errBadRequest, URL: "test"}
+)
+
+func TestNewCircuitBreaker_Disabled(t *testing.T) {
+	is := is.New(t)
+	b := newCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 0})
+	is.True(b == nil)
+	// a nil breaker allows everything
+	is.NoErr(b.allow(context.Background(), "test"))
+	b.done(context.Background(), errTestUpstream)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	// expireOpen pretends the circuit was opened long enough ago to let
+	// probes through.
+	expireOpen := func(b *circuitBreaker) {
+		b.openedAt = time.Now().Add(-2 * b.config.OpenDuration)
+	}
+	// request sends a request through the breaker that fails with err.
+	request := func(b *circuitBreaker, err error) error {
+		if allowErr := b.allow(ctx, "test"); allowErr != nil {
+			return allowErr
+		}
+		b.done(ctx, err)
+		return nil
+	}
+
+	testCases := []struct {
+		name   string
+		probes int
+		run    func(is *is.I, b *circuitBreaker)
+		want   string
+	}{{
+		name: "opens after consecutive failures",
+		run: func(is *is.I, b *circuitBreaker) {
+			for i := 0; i < 2; i++ {
+				is.NoErr(request(b, errTestUpstream))
+			}
+			is.Equal(b.state, circuitClosed)
+			is.NoErr(request(b, errTestUpstream))
+			is.True(errors.Is(request(b, nil), errCircuitOpen))
+		},
+		want: circuitOpen,
+	}, {
+		name: "success resets failures",
+		run: func(is *is.I, b *circuitBreaker) {
+			for i := 0; i < 5; i++ {
+				is.NoErr(request(b, errTestUpstream))
+				is.NoErr(request(b, nil))
+			}
+		},
+		want: circuitClosed,
+	}, {
+		name: "non-retryable errors don't count",
+		run: func(is *is.I, b *circuitBreaker) {
+			for i := 0; i < 5; i++ {
+				is.NoErr(request(b, errTestBadRequest))
+			}
+		},
+		want: circuitClosed,
+	}, {
+		name: "cancelled requests don't count",
+		run: func(is *is.I, b *circuitBreaker) {
+			for i := 0; i < 5; i++ {
+				is.NoErr(request(b, fmt.Errorf("request failed: %w", context.Canceled)))
+			}
+		},
+		want: circuitClosed,
+	}, {
+		name: "half-open after open duration",
+		run: func(is *is.I, b *circuitBreaker) {
+			b.transition(ctx, circuitOpen)
+			expireOpen(b)
+			is.NoErr(b.allow(ctx, "test"))
+			is.Equal(b.state, circuitHalfOpen)
+			// only one probe is let through
+			is.True(errors.Is(b.allow(ctx, "test"), errCircuitOpen))
+		},
+		want: circuitHalfOpen,
+	}, {
+		name: "successful probe closes",
+		run: func(is *is.I, b *circuitBreaker) {
+			b.transition(ctx, circuitOpen)
+			expireOpen(b)
+			is.NoErr(request(b, nil))
+		},
+		want: circuitClosed,
+	}, {
+		name:   "all probes have to succeed",
+		probes: 2,
+		run: func(is *is.I, b *circuitBreaker) {
+			b.transition(ctx, circuitOpen)
+			expireOpen(b)
+			is.NoErr(request(b, nil))
+			is.Equal(b.state, circuitHalfOpen)
+			is.NoErr(request(b, nil))
+		},
+		want: circuitClosed,
+	}, {
+		name: "failed probe opens again",
+		run: func(is *is.I, b *circuitBreaker) {
+			b.transition(ctx, circuitOpen)
+			expireOpen(b)
+			is.NoErr(request(b, errTestUpstream))
+			is.True(errors.Is(request(b, nil), errCircuitOpen))
+		},
+		want: circuitOpen,
+	}, {
+		name: "cancelled probe frees its slot",
+		run: func(is *is.I, b *circuitBreaker) {
+			b.transition(ctx, circuitOpen)
+			expireOpen(b)
+			is.NoErr(request(b, context.Canceled))
+			is.Equal(b.state, circuitHalfOpen)
+			is.NoErr(request(b, nil))
+		},
+		want: circuitClosed,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			b := newCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 3, OpenDuration: time.Minute, HalfOpenProbes: tc.probes})
+			tc.run(is, b)
+			is.Equal(b.state, tc.want)
+		})
+	}
+}
+
+func TestCircuitBreaker_Counters(t *testing.T) {
+	is := is.New(t)
+	var logs bytes.Buffer
+	ctx := zerolog.New(&logs).WithContext(context.Background())
+	b := newCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, OpenDuration: time.Minute})
+
+	// stateChanges returns the counters logged on every state change since
+	// the last call.
+	type stateChange struct {
+		To       string `json:"to"`
+		Opened   int    `json:"opened"`
+		Rejected int    `json:"rejected"`
+	}
+	stateChanges := func() []stateChange {
+		var changes []stateChange
+		dec := json.NewDecoder(&logs)
+		for dec.More() {
+			var line struct {
+				stateChange
+				Message string `json:"message"`
+			}
+			is.NoErr(dec.Decode(&line))
+			if line.Message == "circuit breaker state changed" {
+				changes = append(changes, line.stateChange)
+			}
+		}
+		return changes
+	}
+
+	is.NoErr(b.allow(ctx, "test"))
+	b.done(ctx, errTestUpstream)
+	is.Equal(stateChanges(), []stateChange{{To: circuitOpen, Opened: 1}})
+	for i := 0; i < 3; i++ {
+		is.True(errors.Is(b.allow(ctx, "test"), errCircuitOpen))
+	}
+	is.Equal(b.rejected, 3)
+
+	// the failed probe opens the circuit again, rejections keep adding up
+	b.openedAt = time.Now().Add(-2 * time.Minute)
+	is.NoErr(b.allow(ctx, "test"))
+	is.True(errors.Is(b.allow(ctx, "test"), errCircuitOpen))
+	b.done(ctx, errTestUpstream)
+	is.Equal(stateChanges(), []stateChange{
+		{To: circuitHalfOpen, Opened: 1, Rejected: 3},
+		{To: circuitOpen, Opened: 1, Rejected: 4},
+	})
+
+	b.openedAt = time.Now().Add(-2 * time.Minute)
+	is.NoErr(b.allow(ctx, "test"))
+	b.done(ctx, nil)
+	is.Equal(stateChanges(), []stateChange{
+		{To: circuitHalfOpen, Opened: 1, Rejected: 4},
+		{To: circuitClosed, Opened: 1, Rejected: 4},
+	})
+
+	// opening the closed circuit again starts counting rejections anew
+	is.NoErr(b.allow(ctx, "test"))
+	b.done(ctx, errTestUpstream)
+	is.True(errors.Is(b.allow(ctx, "test"), errCircuitOpen))
+	is.Equal(stateChanges(), []stateChange{{To: circuitOpen, Opened: 2}})
+	is.Equal(b.rejected, 1)
+}
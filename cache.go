@@ -0,0 +1,473 @@
+package nbastats
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// CacheConfig configures the on-disk cache of responses from stats.nba.com.
+type CacheConfig struct {
+	// Dir is the directory responses are cached in. Sources using the same
+	// directory share the cached responses. If empty, responses aren't
+	// cached.
+	Dir string `json:"dir"`
+	// TTL is how long a cached response is used without asking stats.nba.com
+	// if it changed, unless the response specifies it with a Cache-Control
+	// header.
+	TTL time.Duration `json:"ttl" default:"5m"`
+	// EndpointTTL overrides the TTL of single endpoints, as a list of
+	// "endpoint=duration" pairs, e.g. "commonallplayers=24h".
+	EndpointTTL []string `json:"endpointTTL"`
+	// MaxAge is how long a cached response is kept after it was last
+	// downloaded or revalidated, older responses are deleted. 0 keeps
+	// responses forever.
+	MaxAge time.Duration `json:"maxAge" default:"168h"`
+}
+
+// cacheEvictPeriod is how often a cache directory is checked for responses
+// exceeding their max age.
+const cacheEvictPeriod = time.Hour
+
+// endpointTTLs parses the TTLs of single endpoints.
+func (c CacheConfig) endpointTTLs() (map[string]time.Duration, error) {
+	ttls := make(map[string]time.Duration, len(c.EndpointTTL))
+	for _, pair := range c.EndpointTTL {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		endpoint, ttl, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid endpoint ttl %q, expected endpoint=duration", pair)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(ttl))
+		if err != nil {
+			return nil, fmt.Errorf("invalid ttl of endpoint %q: %w", endpoint, err)
+		}
+		ttls[strings.ToLower(strings.TrimSpace(endpoint))] = d
+	}
+	return ttls, nil
+}
+
+// cacheEntry describes a cached response, it is stored next to the response
+// body.
+type cacheEntry struct {
+	URL          string    `json:"url"`
+	StoredAt     time.Time `json:"storedAt"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	// MaxAge is the max-age of the Cache-Control header in seconds, or nil
+	// if the response didn't specify it.
+	MaxAge *int64 `json:"maxAge,omitempty"`
+}
+
+// responseCache serves responses from a cacheStore, using the TTLs of the
+// source it belongs to.
+type responseCache struct {
+	store       *cacheStore
+	ttl         time.Duration
+	endpointTTL map[string]time.Duration
+	maxAge      time.Duration
+}
+
+func newResponseCache(config CacheConfig) (*responseCache, error) {
+	if config.Dir == "" {
+		return nil, nil
+	}
+	endpointTTL, err := config.endpointTTLs()
+	if err != nil {
+		return nil, err
+	}
+	store, err := sharedCacheStore(config.Dir)
+	if err != nil {
+		return nil, err
+	}
+	c := &responseCache{store: store, ttl: config.TTL, endpointTTL: endpointTTL, maxAge: config.MaxAge}
+	err = c.evict(context.Background(), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// evict deletes the cached responses older than the max age, at most once
+// per evict period per cache directory. Keys include rolling date ranges and
+// player IDs, without eviction the directory grows without bound.
+func (c *responseCache) evict(ctx context.Context, now time.Time) error {
+	if c.maxAge <= 0 || !c.store.evictDue(now) {
+		return nil
+	}
+	n, err := c.store.evict(now.Add(-c.maxAge))
+	if err != nil {
+		return fmt.Errorf("failed to evict cached responses: %w", err)
+	}
+	if n > 0 {
+		sdk.Logger(ctx).Debug().Int("responses", n).Str("dir", c.store.dir).Msg("evicted cached responses")
+	}
+	return nil
+}
+
+// open returns the body of the response of the given URL. Fresh cached
+// responses are returned without a request, stale ones are revalidated with
+// a conditional request.
+func (c *responseCache) open(ctx context.Context, client *statsClient, url string) (io.ReadCloser, error) {
+	err := c.evict(ctx, time.Now())
+	if err != nil {
+		sdk.Logger(ctx).Warn().Err(err).Msg("ignoring failed eviction")
+	}
+
+	key := cacheKey(url)
+	// concurrent requests of the same URL, e.g. by sources sharing the
+	// cache, wait for the first one instead of requesting it again
+	unlock := c.store.lock(key)
+	defer unlock()
+
+	entry, err := c.store.entry(key)
+	if err != nil {
+		sdk.Logger(ctx).Warn().Err(err).Str("url", url).Msg("ignoring unreadable cache entry")
+		entry = nil
+	}
+	if entry != nil && c.fresh(entry) {
+		sdk.Logger(ctx).Debug().Str("url", url).Msg("serving stats from cache")
+		return c.store.body(key)
+	}
+
+	header := http.Header{}
+	if entry != nil && entry.ETag != "" {
+		header.Set("If-None-Match", entry.ETag)
+	}
+	if entry != nil && entry.LastModified != "" {
+		header.Set("If-Modified-Since", entry.LastModified)
+	}
+	resp, err := client.request(ctx, url, header)
+	if err != nil {
+		return nil, err
+	}
+
+	noStore, maxAge := cacheControl(resp.Header.Get("Cache-Control"))
+	if resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		if entry == nil {
+			return nil, &requestError{kind: errUpstream, URL: url, StatusCode: resp.StatusCode, err: errors.New("unexpected status 304 Not Modified")}
+		}
+		sdk.Logger(ctx).Debug().Str("url", url).Msg("cached stats not modified")
+		entry.StoredAt = time.Now()
+		if maxAge != nil {
+			entry.MaxAge = maxAge
+		}
+		err = c.store.storeEntry(key, entry)
+		if err != nil {
+			sdk.Logger(ctx).Warn().Err(err).Str("url", url).Msg("failed to update cache entry")
+		}
+		return c.store.body(key)
+	}
+
+	body, err := client.decodeResponse(url, resp)
+	if err != nil || noStore {
+		return body, err
+	}
+	defer body.Close()
+
+	entry = &cacheEntry{
+		URL:          url,
+		StoredAt:     time.Now(),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		MaxAge:       maxAge,
+	}
+	src := &errRecordingReader{r: limitResponseSize(body, client.maxResponseSize)}
+	err = c.store.store(key, entry, src)
+	switch {
+	case src.err != nil:
+		return nil, decodeError(ctx, url, src.err)
+	case err != nil:
+		// the response was fine, requesting it again won't fix the cache
+		return nil, fmt.Errorf("failed to cache response of %s: %w", url, err)
+	}
+	return c.store.body(key)
+}
+
+// errRecordingReader records the error returned by the underlying reader,
+// which tells failed reads of the response apart from failed writes of the
+// cache files.
+type errRecordingReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *errRecordingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+// fresh returns true if the cached response can be used without asking
+// stats.nba.com if it changed.
+func (c *responseCache) fresh(entry *cacheEntry) bool {
+	ttl := c.ttl
+	if endpointTTL, ok := c.endpointTTL[cacheEndpoint(entry.URL)]; ok {
+		ttl = endpointTTL
+	}
+	if entry.MaxAge != nil {
+		ttl = time.Duration(*entry.MaxAge) * time.Second
+	}
+	return time.Since(entry.StoredAt) < ttl
+}
+
+// cacheStore stores responses in a directory. Stores are shared by all
+// sources caching in the same directory.
+type cacheStore struct {
+	dir string
+
+	m     sync.Mutex
+	locks map[string]*keyLock
+	// evicted is the time responses were last evicted.
+	evicted time.Time
+}
+
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+var cacheStores = struct {
+	sync.Mutex
+	stores map[string]*cacheStore
+}{stores: make(map[string]*cacheStore)}
+
+// sharedCacheStore returns the store of the given directory, creating the
+// directory if needed.
+func sharedCacheStore(dir string) (*cacheStore, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache dir: %w", err)
+	}
+	cacheStores.Lock()
+	defer cacheStores.Unlock()
+	if store, ok := cacheStores.stores[dir]; ok {
+		return store, nil
+	}
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache dir: %w", err)
+	}
+	store := &cacheStore{dir: dir, locks: make(map[string]*keyLock)}
+	cacheStores.stores[dir] = store
+	return store, nil
+}
+
+// lock locks the given key and returns the function unlocking it.
+func (s *cacheStore) lock(key string) func() {
+	s.m.Lock()
+	l, ok := s.locks[key]
+	if !ok {
+		l = &keyLock{}
+		s.locks[key] = l
+	}
+	l.refs++
+	s.m.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		s.m.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(s.locks, key)
+		}
+		s.m.Unlock()
+	}
+}
+
+// evictDue returns true if responses weren't evicted within the evict
+// period, the caller is expected to evict them.
+func (s *cacheStore) evictDue(now time.Time) bool {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if now.Sub(s.evicted) < cacheEvictPeriod {
+		return false
+	}
+	s.evicted = now
+	return true
+}
+
+// evict deletes the responses that were last stored or revalidated before
+// the given time, as well as leftover temporary files, and returns the
+// number of deleted responses.
+func (s *cacheStore) evict(before time.Time) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	var errs []error
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || !info.ModTime().Before(before) {
+			continue
+		}
+		name := e.Name()
+		switch {
+		case strings.HasSuffix(name, ".tmp"):
+			errs = append(errs, removeIfExists(filepath.Join(s.dir, name)))
+		case strings.HasSuffix(name, ".meta"):
+			// the entry is rewritten whenever the response is revalidated,
+			// the body is only rewritten when it changed
+			key := strings.TrimSuffix(name, ".meta")
+			unlock := s.lock(key)
+			errs = append(errs,
+				removeIfExists(s.path(key, ".json")),
+				removeIfExists(s.path(key, ".meta")),
+			)
+			unlock()
+			n++
+		}
+	}
+	return n, errors.Join(errs...)
+}
+
+// removeIfExists removes the file at path, if it exists.
+func removeIfExists(path string) error {
+	err := os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// entry returns the cache entry of the given key, or nil if there is none.
+func (s *cacheStore) entry(key string) (*cacheEntry, error) {
+	raw, err := os.ReadFile(s.path(key, ".meta"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entry cacheEntry
+	err = json.Unmarshal(raw, &entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode cache entry: %w", err)
+	}
+	if _, err := os.Stat(s.path(key, ".json")); err != nil {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+// body opens the cached response body of the given key.
+func (s *cacheStore) body(key string) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(key, ".json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cached response: %w", err)
+	}
+	return f, nil
+}
+
+// store writes the response body read from r and its entry. Both files are
+// replaced atomically, so readers in other processes never see a partially
+// written response.
+func (s *cacheStore) store(key string, entry *cacheEntry, r io.Reader) error {
+	err := s.writeFile(key, ".json", func(w io.Writer) error {
+		_, err := io.Copy(w, r)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return s.storeEntry(key, entry)
+}
+
+func (s *cacheStore) storeEntry(key string, entry *cacheEntry) error {
+	return s.writeFile(key, ".meta", func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(entry)
+	})
+}
+
+// writeFile writes a file next to its final path and renames it once it was
+// written completely.
+func (s *cacheStore) writeFile(key, ext string, write func(io.Writer) error) error {
+	f, err := os.CreateTemp(s.dir, key+ext+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	err = write(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	err = os.Rename(f.Name(), s.path(key, ext))
+	if err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	return nil
+}
+
+func (s *cacheStore) path(key, ext string) string {
+	return filepath.Join(s.dir, key+ext)
+}
+
+// cacheKey returns the file name of the cached response of the given URL.
+func cacheKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+// cacheEndpoint returns the endpoint requested by the given URL.
+func cacheEndpoint(url string) string {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(path.Base(u.Path))
+}
+
+// cacheControl parses the Cache-Control header of a response. It returns if
+// the response must not be stored and its max-age, if specified. no-cache
+// is treated as a max-age of 0, the response is stored but revalidated
+// before every use.
+func cacheControl(header string) (noStore bool, maxAge *int64) {
+	for _, directive := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store":
+			noStore = true
+		case "no-cache":
+			zero := int64(0)
+			maxAge = &zero
+		case "max-age":
+			if maxAge != nil {
+				continue
+			}
+			seconds, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64)
+			if err == nil {
+				maxAge = &seconds
+			}
+		}
+	}
+	return noStore, maxAge
+}
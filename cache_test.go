@@ -0,0 +1,219 @@
+package nbastats
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+// cacheServer serves a response with the given headers and answers
+// conditional requests with the ETag "v1" with 304 Not Modified.
+type cacheServer struct {
+	body   string
+	header http.Header
+
+	mu          sync.Mutex
+	requests    int
+	conditional int
+}
+
+func (s *cacheServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	if r.Header.Get("If-None-Match") != "" {
+		s.conditional++
+	}
+	s.mu.Unlock()
+
+	for name, values := range s.header {
+		w.Header()[name] = values
+	}
+	if r.Header.Get("If-None-Match") == "v1" {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	_, _ = io.WriteString(w, s.body)
+}
+
+func newTestCache(t *testing.T, config CacheConfig) (*responseCache, string) {
+	config.Dir = t.TempDir()
+	cache, err := newResponseCache(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cache, config.Dir
+}
+
+func readCached(ctx context.Context, cache *responseCache, client *statsClient, url string) (string, error) {
+	body, err := cache.open(ctx, client, url)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	raw, err := io.ReadAll(body)
+	return string(raw), err
+}
+
+func TestResponseCache(t *testing.T) {
+	const url = "https://stats.nba.com/stats/leaguedashptstats?Season=2023-24"
+	testCases := []struct {
+		name   string
+		config CacheConfig
+		header http.Header
+		// stale makes the cached entry older than any TTL before the
+		// second request.
+		stale       bool
+		requests    int
+		conditional int
+	}{{
+		name:     "fresh",
+		config:   CacheConfig{TTL: time.Hour},
+		requests: 1,
+	}, {
+		name:        "stale",
+		config:      CacheConfig{TTL: time.Hour},
+		header:      http.Header{"Etag": {"v1"}},
+		stale:       true,
+		requests:    2,
+		conditional: 1,
+	}, {
+		name:        "max-age overrides ttl",
+		config:      CacheConfig{TTL: time.Hour},
+		header:      http.Header{"Etag": {"v1"}, "Cache-Control": {"max-age=0"}},
+		requests:    2,
+		conditional: 1,
+	}, {
+		name:        "endpoint ttl",
+		config:      CacheConfig{TTL: time.Hour, EndpointTTL: []string{"LeagueDashPtStats=0s"}},
+		header:      http.Header{"Etag": {"v1"}},
+		requests:    2,
+		conditional: 1,
+	}, {
+		name:     "no-store",
+		config:   CacheConfig{TTL: time.Hour},
+		header:   http.Header{"Etag": {"v1"}, "Cache-Control": {"no-store"}},
+		requests: 2,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			ctx := context.Background()
+			srv := &cacheServer{body: `{"resultSets":[]}`, header: tc.header}
+			client := newTestClient(srv.serve)
+			cache, _ := newTestCache(t, tc.config)
+
+			body, err := readCached(ctx, cache, client, url)
+			is.NoErr(err)
+			is.Equal(body, srv.body)
+
+			if tc.stale {
+				key := cacheKey(url)
+				entry, err := cache.store.entry(key)
+				is.NoErr(err)
+				entry.StoredAt = entry.StoredAt.Add(-2 * time.Hour)
+				is.NoErr(cache.store.storeEntry(key, entry))
+			}
+
+			body, err = readCached(ctx, cache, client, url)
+			is.NoErr(err)
+			is.Equal(body, srv.body)
+			is.Equal(srv.requests, tc.requests)
+			is.Equal(srv.conditional, tc.conditional)
+		})
+	}
+}
+
+func TestResponseCache_TooLarge(t *testing.T) {
+	is := is.New(t)
+	srv := &cacheServer{body: `{"resultSets":[]}`}
+	client := newTestClient(srv.serve)
+	client.maxResponseSize = 5
+	cache, _ := newTestCache(t, CacheConfig{TTL: time.Hour})
+
+	_, err := readCached(context.Background(), cache, client, "https://stats.nba.com/stats/test")
+	is.True(errors.Is(err, errResponseTooLarge))
+	is.True(!isRetryable(err))
+}
+
+func TestResponseCache_WriteFailure(t *testing.T) {
+	is := is.New(t)
+	srv := &cacheServer{body: `{"resultSets":[]}`}
+	client := newTestClient(srv.serve)
+	cache, dir := newTestCache(t, CacheConfig{TTL: time.Hour})
+	is.NoErr(os.RemoveAll(dir))
+
+	_, err := readCached(context.Background(), cache, client, "https://stats.nba.com/stats/test")
+	is.True(err != nil)
+	is.True(!errors.Is(err, errUpstream))
+	is.True(!isRetryable(err))
+}
+
+func TestCacheControl(t *testing.T) {
+	seconds := func(n int64) *int64 { return &n }
+	testCases := []struct {
+		header  string
+		noStore bool
+		maxAge  *int64
+	}{
+		{header: ""},
+		{header: "public"},
+		{header: "max-age=60", maxAge: seconds(60)},
+		{header: `public, max-age="30"`, maxAge: seconds(30)},
+		{header: "max-age=abc"},
+		{header: "no-cache", maxAge: seconds(0)},
+		{header: "no-cache, max-age=60", maxAge: seconds(0)},
+		{header: "No-Store", noStore: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.header, func(t *testing.T) {
+			is := is.New(t)
+			noStore, maxAge := cacheControl(tc.header)
+			is.Equal(noStore, tc.noStore)
+			is.Equal(maxAge, tc.maxAge)
+		})
+	}
+}
+
+func TestResponseCache_Evict(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	srv := &cacheServer{body: `{"resultSets":[]}`}
+	client := newTestClient(srv.serve)
+	cache, dir := newTestCache(t, CacheConfig{TTL: time.Hour, MaxAge: 24 * time.Hour})
+
+	for _, url := range []string{"https://stats.nba.com/stats/old", "https://stats.nba.com/stats/new"} {
+		_, err := readCached(ctx, cache, client, url)
+		is.NoErr(err)
+	}
+	// the old response was last stored two days ago
+	old := time.Now().Add(-48 * time.Hour)
+	for _, ext := range []string{".json", ".meta"} {
+		is.NoErr(os.Chtimes(cache.store.path(cacheKey("https://stats.nba.com/stats/old"), ext), old, old))
+	}
+
+	// responses are evicted at most once per evict period
+	is.NoErr(cache.evict(ctx, time.Now()))
+	entries, err := os.ReadDir(dir)
+	is.NoErr(err)
+	is.Equal(len(entries), 4)
+
+	is.NoErr(cache.evict(ctx, time.Now().Add(cacheEvictPeriod)))
+	entries, err = os.ReadDir(dir)
+	is.NoErr(err)
+	is.Equal(len(entries), 2)
+	entry, err := cache.store.entry(cacheKey("https://stats.nba.com/stats/new"))
+	is.NoErr(err)
+	is.True(entry != nil)
+
+	// evicted responses are requested again
+	_, err = readCached(ctx, cache, client, "https://stats.nba.com/stats/old")
+	is.NoErr(err)
+	is.Equal(srv.requests, 3)
+}
@@ -0,0 +1,260 @@
+package nbastats
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Cassette modes.
+const (
+	cassetteOff    = "off"
+	cassetteRecord = "record"
+	cassetteReplay = "replay"
+)
+
+// errNotRecorded is returned in replay mode for requests that aren't part
+// of the cassette.
+var errNotRecorded = errors.New("request not recorded in cassette")
+
+// CassetteConfig configures recording requests and responses to a cassette
+// and replaying them from it.
+type CassetteConfig struct {
+	// Mode is "off", "record" to record every request and response to the
+	// cassette, or "replay" to serve all requests from the cassette without
+	// any network access.
+	Mode string `json:"mode" validate:"inclusion=off|record|replay" default:"off"`
+	// Dir is the cassette directory.
+	Dir string `json:"dir"`
+}
+
+func (c CassetteConfig) validate() error {
+	switch c.Mode {
+	case "", cassetteOff:
+		return nil
+	case cassetteRecord, cassetteReplay:
+		if c.Dir == "" {
+			return fmt.Errorf("cassette mode %q requires a cassette dir", c.Mode)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported cassette mode %q", c.Mode)
+	}
+}
+
+// roundTripper wraps transport to record to or replay from the cassette.
+// season is the configured season, replayed requests are matched on it (see
+// cassetteKey).
+func (c CassetteConfig) roundTripper(transport http.RoundTripper, season string) (http.RoundTripper, error) {
+	switch c.Mode {
+	case cassetteRecord:
+		return newCassetteRecorder(c.Dir, transport)
+	case cassetteReplay:
+		return newCassettePlayer(c.Dir, season)
+	default:
+		return transport, nil
+	}
+}
+
+// interaction is a recorded request and its response, stored as one JSON
+// file in the cassette directory. The body is stored as received, before
+// any content encoding is decoded.
+type interaction struct {
+	Request struct {
+		Method string `json:"method"`
+		URL    string `json:"url"`
+	} `json:"request"`
+	Response struct {
+		StatusCode int         `json:"statusCode"`
+		Header     http.Header `json:"header"`
+		Body       []byte      `json:"body"`
+	} `json:"response"`
+}
+
+// cassetteRecorder sends requests with the underlying transport and records
+// every response.
+type cassetteRecorder struct {
+	dir       string
+	transport http.RoundTripper
+	seq       int64
+}
+
+func newCassetteRecorder(dir string, transport http.RoundTripper) (*cassetteRecorder, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cassette dir: %w", err)
+	}
+	// append to an existing cassette, after its last interaction
+	files, err := interactionFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	var seq int64
+	for _, file := range files {
+		n, ok := interactionSeq(file)
+		if ok && n > seq {
+			seq = n
+		}
+	}
+	return &cassetteRecorder{dir: dir, transport: transport, seq: seq}, nil
+}
+
+func (r *cassetteRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	var in interaction
+	in.Request.Method = req.Method
+	in.Request.URL = req.URL.String()
+	in.Response.StatusCode = resp.StatusCode
+	in.Response.Header = resp.Header
+	in.Response.Body = body
+	raw, err := json.MarshalIndent(in, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode interaction: %w", err)
+	}
+	seq := atomic.AddInt64(&r.seq, 1)
+	err = os.WriteFile(filepath.Join(r.dir, fmt.Sprintf("interaction-%06d.json", seq)), raw, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record interaction: %w", err)
+	}
+	return resp, nil
+}
+
+// CloseIdleConnections closes the idle connections of the underlying
+// transport.
+func (r *cassetteRecorder) CloseIdleConnections() {
+	if c, ok := r.transport.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// cassettePlayer answers requests with the recorded responses. Responses of
+// a request recorded multiple times are replayed in the recorded order, the
+// last one is repeated once all were replayed.
+type cassettePlayer struct {
+	season string
+
+	m            sync.Mutex
+	interactions map[string][]*interaction
+}
+
+func newCassettePlayer(dir, season string) (*cassettePlayer, error) {
+	files, err := interactionFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no interactions found in cassette %q", dir)
+	}
+	p := &cassettePlayer{season: season, interactions: make(map[string][]*interaction)}
+	for _, file := range files {
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read interaction: %w", err)
+		}
+		var in interaction
+		err = json.Unmarshal(raw, &in)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode interaction %q: %w", filepath.Base(file), err)
+		}
+		key := in.Request.Method + " " + cassetteKey(in.Request.URL, p.season)
+		p.interactions[key] = append(p.interactions[key], &in)
+	}
+	return p, nil
+}
+
+func (p *cassettePlayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.Method + " " + cassetteKey(req.URL.String(), p.season)
+	p.m.Lock()
+	recorded := p.interactions[key]
+	if len(recorded) == 0 {
+		p.m.Unlock()
+		return nil, fmt.Errorf("%w: %s", errNotRecorded, key)
+	}
+	in := recorded[0]
+	if len(recorded) > 1 {
+		p.interactions[key] = recorded[1:]
+	}
+	p.m.Unlock()
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.Response.StatusCode, http.StatusText(in.Response.StatusCode)),
+		StatusCode:    in.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        in.Response.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(in.Response.Body)),
+		ContentLength: int64(len(in.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// cassetteKey returns the URL a recorded request is matched by. The
+// DateFrom and DateTo parameters of rolling day windows depend on the day
+// the request was sent, they are replaced by the number of days they span,
+// so cassettes can be replayed on any day and windows of different lengths
+// are still told apart. If season is "current", the Season parameter is
+// matched on it instead of the season it resolved to when the request was
+// sent.
+func cassetteKey(rawURL, season string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	values := u.Query()
+	if days, ok := windowDays(values.Get("DateFrom"), values.Get("DateTo")); ok {
+		values.Del("DateFrom")
+		values.Del("DateTo")
+		values.Set("WindowDays", strconv.Itoa(days))
+	}
+	if season == "current" && values.Has("Season") {
+		values.Set("Season", season)
+	}
+	u.RawQuery = values.Encode()
+	return u.String()
+}
+
+// interactionFiles returns the interaction files of a cassette in recorded
+// order.
+func interactionFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette dir: %w", err)
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), "interaction-") && strings.HasSuffix(e.Name(), ".json") {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// interactionSeq returns the sequence number of an interaction file.
+func interactionSeq(file string) (int64, bool) {
+	name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), "interaction-"), ".json")
+	n, err := strconv.ParseInt(name, 10, 64)
+	return n, err == nil
+}
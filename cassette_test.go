@@ -0,0 +1,120 @@
+package nbastats
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestCassetteRecorder_Append(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	// a cassette with a gap, e.g. after an interaction was deleted by hand
+	for _, name := range []string{"interaction-000001.json", "interaction-000003.json"} {
+		is.NoErr(os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644))
+	}
+
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(http.NoBody)}, nil
+	})
+	recorder, err := newCassetteRecorder(dir, transport)
+	is.NoErr(err)
+	req, err := http.NewRequest("GET", "https://stats.nba.com/stats/test", nil)
+	is.NoErr(err)
+	_, err = recorder.RoundTrip(req)
+	is.NoErr(err)
+
+	files, err := interactionFiles(dir)
+	is.NoErr(err)
+	is.Equal(len(files), 3)
+	is.Equal(filepath.Base(files[2]), "interaction-000004.json")
+	// existing interactions are kept
+	raw, err := os.ReadFile(files[1])
+	is.NoErr(err)
+	is.Equal(string(raw), "interaction-000003.json")
+}
+
+func TestCassettePlayer_Season(t *testing.T) {
+	const recorded = "https://stats.nba.com/stats/leaguedashptstats?LeagueID=00&Season=2023-24"
+	testCases := []struct {
+		name    string
+		season  string
+		url     string
+		matches bool
+	}{
+		{"same season", "2023-24", "https://stats.nba.com/stats/leaguedashptstats?LeagueID=00&Season=2023-24", true},
+		{"other season", "2024-25", "https://stats.nba.com/stats/leaguedashptstats?LeagueID=00&Season=2024-25", false},
+		{"current season", "current", "https://stats.nba.com/stats/leaguedashptstats?LeagueID=00&Season=2024-25", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			dir := t.TempDir()
+			transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(http.NoBody)}, nil
+			})
+			recorder, err := newCassetteRecorder(dir, transport)
+			is.NoErr(err)
+			req, err := http.NewRequest("GET", recorded, nil)
+			is.NoErr(err)
+			_, err = recorder.RoundTrip(req)
+			is.NoErr(err)
+
+			player, err := newCassettePlayer(dir, tc.season)
+			is.NoErr(err)
+			req, err = http.NewRequest("GET", tc.url, nil)
+			is.NoErr(err)
+			_, err = player.RoundTrip(req)
+			is.Equal(err == nil, tc.matches)
+		})
+	}
+}
+
+func TestCassettePlayer_DayWindows(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	// recorded on one day, replayed on a later one
+	recorded := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	replayed := time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC)
+	windows, err := parseWindows([]string{"season", "last7d", "last30d"})
+	is.NoErr(err)
+
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		// the body identifies the window the response was recorded for
+		body := req.URL.Query().Get("DateFrom")
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})
+	recorder, err := newCassetteRecorder(dir, transport)
+	is.NoErr(err)
+	for _, w := range windows {
+		req, err := http.NewRequest("GET", buildNBAStatsURL(w.apply(NewNBAStatsQueryParams(), recorded)), nil)
+		is.NoErr(err)
+		_, err = recorder.RoundTrip(req)
+		is.NoErr(err)
+	}
+
+	player, err := newCassettePlayer(dir, "2023-24")
+	is.NoErr(err)
+	for _, w := range windows {
+		req, err := http.NewRequest("GET", buildNBAStatsURL(w.apply(NewNBAStatsQueryParams(), replayed)), nil)
+		is.NoErr(err)
+		resp, err := player.RoundTrip(req)
+		is.NoErr(err)
+		body, err := io.ReadAll(resp.Body)
+		is.NoErr(err)
+		is.Equal(string(body), w.apply(NewNBAStatsQueryParams(), recorded).DateFrom)
+	}
+
+	// windows that weren't recorded don't match another window
+	req, err := http.NewRequest("GET", buildNBAStatsURL(statsWindow{Name: "last14d", Days: 14}.apply(NewNBAStatsQueryParams(), replayed)), nil)
+	is.NoErr(err)
+	_, err = player.RoundTrip(req)
+	is.True(errors.Is(err, errNotRecorded))
+}
@@ -0,0 +1,249 @@
+package nbastats
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// HTTPConfig configures the HTTP client used to query stats.nba.com.
+type HTTPConfig struct {
+	// RequestTimeout is the maximum time to wait for the response headers of
+	// a request. Reading the response body isn't limited, since responses are
+	// streamed while their records are read.
+	RequestTimeout time.Duration `json:"requestTimeout" default:"30s"`
+	// ConnectTimeout is the maximum time to wait for a connection, including
+	// the TLS handshake.
+	ConnectTimeout time.Duration `json:"connectTimeout" default:"10s"`
+	// MaxIdleConns is the maximum number of idle keep-alive connections.
+	MaxIdleConns int `json:"maxIdleConns" default:"10"`
+	// IdleConnTimeout is how long an idle keep-alive connection is kept open.
+	IdleConnTimeout time.Duration `json:"idleConnTimeout" default:"90s"`
+	// MaxResponseSize is the maximum size of a decoded response body in
+	// bytes, larger responses are rejected. 0 means no limit.
+	MaxResponseSize int64 `json:"maxResponseSize" default:"0"`
+	// HeaderProfile is the named set of headers sent with every request, one
+	// of stats, browser or minimal.
+	HeaderProfile string `json:"headerProfile" validate:"inclusion=stats|browser|minimal" default:"stats"`
+	// Headers are additional headers sent with every request, one
+	// "Name: value" pair per line. They override headers of the profile.
+	Headers string `json:"headers"`
+	// UserAgents is a list of user agents, one per line, that requests rotate
+	// through. If empty, the user agent of the header profile is used.
+	UserAgents string `json:"userAgents"`
+	// Proxy configures the proxy requests are sent through.
+	Proxy ProxyConfig `json:"proxy"`
+	// TLS configures custom certificates.
+	TLS TLSConfig `json:"tls"`
+	// CircuitBreaker configures when requests are paused because
+	// stats.nba.com keeps failing.
+	CircuitBreaker CircuitBreakerConfig `json:"circuitBreaker"`
+	// Cache configures the on-disk cache of responses.
+	Cache CacheConfig `json:"cache"`
+	// Cassette configures recording and replaying requests.
+	Cassette CassetteConfig `json:"cassette"`
+}
+
+// statsClient sends requests to stats.nba.com.
+type statsClient struct {
+	client          *http.Client
+	maxResponseSize int64
+	headers         http.Header
+	userAgents      *userAgentRotator
+	breaker         *circuitBreaker
+	cache           *responseCache
+}
+
+// newStatsClient returns a client configured by config. season is the
+// configured season, e.g. "current", which replayed cassettes are matched on.
+func newStatsClient(config HTTPConfig, season string) (*statsClient, error) {
+	headers, err := config.requestHeaders()
+	if err != nil {
+		return nil, err
+	}
+	var userAgents *userAgentRotator
+	if ua := config.userAgentRotation(); len(ua) > 0 {
+		userAgents = &userAgentRotator{userAgents: ua}
+	}
+
+	proxy, err := config.Proxy.proxyFunc()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := config.TLS.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := newResponseCache(config.Cache)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   config.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   config.ConnectTimeout,
+		ResponseHeaderTimeout: config.RequestTimeout,
+		MaxIdleConns:          config.MaxIdleConns,
+		MaxIdleConnsPerHost:   config.MaxIdleConns,
+		IdleConnTimeout:       config.IdleConnTimeout,
+	}
+	roundTripper, err := config.Cassette.roundTripper(transport, season)
+	if err != nil {
+		return nil, err
+	}
+	return &statsClient{
+		client:          &http.Client{Transport: roundTripper},
+		maxResponseSize: config.MaxResponseSize,
+		headers:         headers,
+		userAgents:      userAgents,
+		breaker:         newCircuitBreaker(config.CircuitBreaker),
+		cache:           cache,
+	}, nil
+}
+
+// close releases the idle connections held by the client.
+func (c *statsClient) close() {
+	c.client.CloseIdleConnections()
+}
+
+func (c *statsClient) fetchNBASpeedDistanceStats(ctx context.Context, params NBAStatsQueryParams) ([]byte, error) {
+	// url := "https://stats.nba.com/stats/leaguedashptstats?College=&Conference=&Country=&DateFrom=&DateTo=&Division=&DraftPick=&DraftYear=&GameScope=&Height=&ISTRound=&LastNGames=0&LeagueID=00&Location=&Month=0&OpponentTeamID=0&Outcome=&PORound=0&PerMode=PerGame&PlayerExperience=&PlayerOrTeam=Player&PlayerPosition=&PtMeasureType=SpeedDistance&Season=2023-24&SeasonSegment=&SeasonType=Regular%20Season&StarterBench=&TeamID=0&VsConference=&VsDivision=&Weight="
+	return c.fetchNBAStats(ctx, buildNBAStatsURL(params))
+}
+
+// fetchNBAStatsResponse fetches the given endpoint and decodes the response.
+func (c *statsClient) fetchNBAStatsResponse(ctx context.Context, endpoint string, values url.Values) (ResponseData, error) {
+	var data ResponseData
+	url := buildEndpointURL(endpoint, values)
+	body, err := c.openNBAStats(ctx, url)
+	if err != nil {
+		return data, err
+	}
+	defer body.Close()
+
+	err = json.NewDecoder(body).Decode(&data)
+	if err != nil {
+		return data, decodeError(ctx, url, err)
+	}
+	return data, nil
+}
+
+// fetchNBAStats requests the given URL and returns the response body. The
+// request is aborted as soon as ctx is cancelled.
+func (c *statsClient) fetchNBAStats(ctx context.Context, url string) ([]byte, error) {
+	body, err := c.openNBAStats(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	// Read response body
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, decodeError(ctx, url, err)
+	}
+	return data, nil
+}
+
+// openNBAStats requests the given URL and returns the decoded response body,
+// which the caller has to close. Reading the body fails once it exceeds the
+// maximum response size. Failed requests are reported as a *requestError.
+func (c *statsClient) openNBAStats(ctx context.Context, url string) (io.ReadCloser, error) {
+	var body io.ReadCloser
+	var err error
+	if c.cache != nil {
+		body, err = c.cache.open(ctx, c, url)
+	} else {
+		body, err = c.fetch(ctx, url, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return limitResponseSize(body, c.maxResponseSize), nil
+}
+
+// fetch requests the given URL and returns the decoded response body.
+func (c *statsClient) fetch(ctx context.Context, url string, header http.Header) (io.ReadCloser, error) {
+	resp, err := c.request(ctx, url, header)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, statusError(url, resp)
+	}
+	return c.decodeResponse(url, resp)
+}
+
+// request sends a request to the given URL, adding header to the configured
+// headers. Responses with the status code 200 or 304 are returned, all other
+// responses are reported as a *requestError.
+func (c *statsClient) request(ctx context.Context, url string, header http.Header) (*http.Response, error) {
+	err := c.breaker.allow(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.send(ctx, url, header)
+	c.breaker.done(ctx, err)
+	return resp, err
+}
+
+func (c *statsClient) send(ctx context.Context, url string, header http.Header) (*http.Response, error) {
+	sdk.Logger(ctx).Debug().Str("url", url).Msg("requesting stats")
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, &requestError{kind: errBadRequest, URL: url, err: err}
+	}
+
+	// Set the required headers
+	for name, values := range c.headers {
+		req.Header[name] = values
+	}
+	if c.userAgents != nil {
+		req.Header.Set("User-Agent", c.userAgents.userAgent())
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	// Setting Accept-Encoding manually disables the transparent gzip handling
+	// of the http.Client, all encodings are decoded by decodeBody instead.
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+
+	// Make the request
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, transportError(ctx, url, err)
+	}
+
+	// Check for status code 200 OK (or 304 Not Modified if requested)
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotModified {
+		resp.Body.Close()
+		return nil, statusError(url, resp)
+	}
+	return resp, nil
+}
+
+// decodeResponse returns the decoded body of resp, handling gzip, deflate
+// and brotli encoding.
+func (c *statsClient) decodeResponse(url string, resp *http.Response) (io.ReadCloser, error) {
+	reader, err := decodeBody(resp)
+	if err != nil {
+		resp.Body.Close()
+		return nil, &requestError{kind: errDecode, URL: url, StatusCode: resp.StatusCode, err: err}
+	}
+	return reader, nil
+}
@@ -0,0 +1,89 @@
+package nbastats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/matryer/is"
+)
+
+// newServerClient returns a stats client created from config whose requests
+// to stats.nba.com are sent to srv instead.
+func newServerClient(t *testing.T, srv *httptest.Server, config HTTPConfig) *statsClient {
+	t.Helper()
+	c, err := newStatsClient(config, "current")
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := c.client.Transport
+	c.client.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host == "stats.nba.com" {
+			req = req.Clone(req.Context())
+			req.URL.Scheme, req.URL.Host = target.Scheme, target.Host
+		}
+		return next.RoundTrip(req)
+	})
+	t.Cleanup(c.close)
+	return c
+}
+
+func TestStatsClient_SlowBody(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	const rows = 10
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the body takes several times the request timeout to arrive
+		fmt.Fprint(w, `{"resultSets":[{"name":"A","headers":["PLAYER_ID"],"rowSet":[`)
+		for i := 1; i <= rows; i++ {
+			if i > 1 {
+				fmt.Fprint(w, ",")
+			}
+			fmt.Fprintf(w, "[%d]", i)
+			w.(http.Flusher).Flush()
+			time.Sleep(20 * time.Millisecond)
+		}
+		fmt.Fprint(w, "]}]}")
+	}))
+	defer srv.Close()
+	c := newServerClient(t, srv, HTTPConfig{RequestTimeout: 50 * time.Millisecond})
+
+	url := buildNBAStatsURL(NewNBAStatsQueryParams())
+	body, err := c.openNBAStats(ctx, url)
+	is.NoErr(err)
+	st := newRowStream(url, body, nil, windowSeason, "PerGame", "ts", 0)
+	defer st.close()
+	for i := 1; i <= rows; i++ {
+		rec, err := st.next(ctx)
+		is.NoErr(err)
+		is.Equal(rec.Key, sdk.StructuredData{"PLAYER_ID": i})
+	}
+	_, err = st.next(ctx)
+	is.Equal(err, io.EOF)
+}
+
+func TestStatsClient_SlowHeaders(t *testing.T) {
+	is := is.New(t)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+	c := newServerClient(t, srv, HTTPConfig{RequestTimeout: 50 * time.Millisecond})
+
+	_, err := c.openNBAStats(context.Background(), buildNBAStatsURL(NewNBAStatsQueryParams()))
+	is.True(errors.Is(err, errTimeout))
+	is.True(isRetryable(err))
+}
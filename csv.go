@@ -0,0 +1,333 @@
+package nbastats
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// csvWriter appends the rows of incoming records to CSV files, one file
+// per collection and season. Only the latest file of every series of names
+// is kept open (see nameTemplate.seriesFileName), so that templates naming
+// files by date don't keep a file open for every day.
+type csvWriter struct {
+	dir   string
+	names nameTemplate
+	files map[string]*csvFile
+	// series maps a series to the name of its open file.
+	series map[string]string
+}
+
+func newCSVWriter(dir string, names nameTemplate) (*csvWriter, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output dir: %w", err)
+	}
+	return &csvWriter{dir: dir, names: names, files: make(map[string]*csvFile), series: make(map[string]string)}, nil
+}
+
+func (w *csvWriter) write(ctx context.Context, records []sdk.Record) (int, error) {
+	written := make(map[*csvFile]bool)
+	for i, rec := range records {
+		fields, err := recordFields(rec)
+		if err != nil {
+			return i, fmt.Errorf("invalid record %d: %w", i, err)
+		}
+		if fields == nil {
+			sdk.Logger(ctx).Debug().Str("operation", rec.Operation.String()).Msg("skipping record without payload")
+			continue
+		}
+		f, err := w.file(rec, written)
+		if err != nil {
+			return i, err
+		}
+		err = f.write(fields)
+		if err != nil {
+			return i, fmt.Errorf("failed to write record %d to %q: %w", i, f.path, err)
+		}
+		written[f] = true
+	}
+	for f := range written {
+		err := f.flush()
+		if err != nil {
+			return 0, fmt.Errorf("failed to write %q: %w", f.path, err)
+		}
+	}
+	return len(records), nil
+}
+
+func (w *csvWriter) close() error {
+	var errs []error
+	for _, f := range w.files {
+		errs = append(errs, f.close())
+	}
+	return errors.Join(errs...)
+}
+
+// file returns the file the record is written to. The previous file of the
+// record's series is closed and removed from written, if it is a different
+// file.
+func (w *csvWriter) file(rec sdk.Record, written map[*csvFile]bool) (*csvFile, error) {
+	name, err := w.names.fileName(rec, time.Now(), 0)
+	if err != nil {
+		return nil, err
+	}
+	name += ".csv"
+	if f, ok := w.files[name]; ok {
+		return f, nil
+	}
+
+	series, err := w.names.seriesFileName(rec)
+	if err != nil {
+		return nil, err
+	}
+	if previous, ok := w.series[series]; ok {
+		f := w.files[previous]
+		delete(w.files, previous)
+		delete(w.series, series)
+		delete(written, f)
+		err = f.close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to close %q: %w", f.path, err)
+		}
+	}
+
+	f, err := openCSVFile(filepath.Join(w.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	w.files[name] = f
+	w.series[series] = name
+	return f, nil
+}
+
+// csvFile is a CSV file rows are appended to. The header is derived from
+// the first row, columns of later rows missing from the header are added
+// by rewriting the file with the extended header.
+type csvFile struct {
+	path   string
+	header []string
+	file   *os.File
+	w      *csv.Writer
+}
+
+// openCSVFile opens the file for appending, reading the header of an
+// existing file.
+func openCSVFile(path string) (*csvFile, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %q: %w", path, err)
+	}
+	header, err := csv.NewReader(file).Read()
+	if err != nil && !errors.Is(err, io.EOF) {
+		file.Close()
+		return nil, fmt.Errorf("failed to read header of %q: %w", path, err)
+	}
+	return &csvFile{path: path, header: header, file: file, w: csv.NewWriter(file)}, nil
+}
+
+func (f *csvFile) write(fields sdk.StructuredData) error {
+	if f.header == nil {
+		f.header = columnOrder(fields)
+		err := f.w.Write(f.header)
+		if err != nil {
+			return err
+		}
+	}
+	if added := f.newColumns(fields); len(added) > 0 {
+		err := f.extendHeader(added)
+		if err != nil {
+			return fmt.Errorf("failed to add columns %v: %w", added, err)
+		}
+	}
+
+	row := make([]string, len(f.header))
+	for i, column := range f.header {
+		row[i] = formatCSVValue(column, fields[column])
+	}
+	return f.w.Write(row)
+}
+
+// newColumns returns the fields that are missing from the header, in the
+// order they are added.
+func (f *csvFile) newColumns(fields sdk.StructuredData) []string {
+	known := make(map[string]bool, len(f.header))
+	for _, column := range f.header {
+		known[column] = true
+	}
+	var added []string
+	for _, column := range columnOrder(fields) {
+		if !known[column] {
+			added = append(added, column)
+		}
+	}
+	return added
+}
+
+// extendHeader rewrites the file with the added columns appended to the
+// header and left empty in all existing rows. The file is replaced
+// atomically, so it never contains rows that don't match its header.
+func (f *csvFile) extendHeader(added []string) error {
+	err := f.flush()
+	if err != nil {
+		return err
+	}
+	_, err = f.file.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	r := csv.NewReader(f.file)
+	r.FieldsPerRecord = -1
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := csv.NewWriter(tmp)
+
+	header := append(append([]string(nil), f.header...), added...)
+	// skip the old header
+	_, err = r.Read()
+	if err == nil {
+		err = w.Write(header)
+	}
+	for err == nil {
+		var row []string
+		row, err = r.Read()
+		if err == nil {
+			err = w.Write(append(row, make([]string, len(header)-len(row))...))
+		}
+	}
+	if !errors.Is(err, io.EOF) {
+		tmp.Close()
+		return err
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return err
+	}
+
+	f.file.Close()
+	f.file, err = os.OpenFile(f.path, os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	f.w = csv.NewWriter(f.file)
+	f.header = header
+	return nil
+}
+
+func (f *csvFile) flush() error {
+	f.w.Flush()
+	return f.w.Error()
+}
+
+func (f *csvFile) close() error {
+	err := f.flush()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// csvSnapshot is the CSV file of a snapshot. It is written next to its final
+// path and renamed once complete.
+type csvSnapshot struct {
+	path string
+	file *csvFile
+}
+
+func newCSVSnapshotWriter(dir string, names nameTemplate) (*snapshotWriter, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output dir: %w", err)
+	}
+	return newSnapshotWriter(names, func(_ sdk.Record, name string) (snapshotFile, error) {
+		return createCSVSnapshot(filepath.Join(dir, name+".csv"))
+	}), nil
+}
+
+func createCSVSnapshot(path string) (*csvSnapshot, error) {
+	// rows are appended to the file, remove the file of a snapshot that was
+	// never completed
+	err := os.Remove(inProgressPath(path))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove incomplete snapshot: %w", err)
+	}
+	f, err := openCSVFile(inProgressPath(path))
+	if err != nil {
+		return nil, err
+	}
+	return &csvSnapshot{path: path, file: f}, nil
+}
+
+func (s *csvSnapshot) write(rec sdk.Record) error {
+	fields, err := recordFields(rec)
+	if err != nil || fields == nil {
+		return err
+	}
+	return s.file.write(fields)
+}
+
+func (s *csvSnapshot) complete() error {
+	err := s.file.close()
+	if err == nil {
+		err = os.Rename(s.file.path, s.path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to complete %q: %w", s.path, err)
+	}
+	return nil
+}
+
+func (s *csvSnapshot) discard() error {
+	return discardFile(s.file.file)
+}
+
+// formatCSVValue formats a field value. IDs are written as integers, since
+// stats.nba.com returns all numbers as floats, and other numbers without an
+// exponent.
+func formatCSVValue(column string, v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		if strings.HasSuffix(column, "_ID") && v == math.Trunc(v) {
+			return strconv.FormatInt(int64(v), 10)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	case map[string]interface{}, []interface{}:
+		raw, _ := json.Marshal(v)
+		return string(raw)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,49 @@
+package nbastats
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/matryer/is"
+)
+
+func TestCSVWriter_ClosesPreviousFiles(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	names, err := parseNameTemplate("{{.Collection}}_{{.Date}}", "")
+	is.NoErr(err)
+	w, err := newCSVWriter(dir, names)
+	is.NoErr(err)
+
+	record := func(collection, date string, playerID float64) sdk.Record {
+		metadata := sdk.Metadata{metadataCollection: collection, metadataDate: date}
+		return sdk.Util.Source.NewRecordCreate(nil, metadata, nil, sdk.StructuredData{"PLAYER_ID": playerID})
+	}
+	n, err := w.write(ctx, []sdk.Record{
+		record("A", "2024-01-30", 1),
+		record("B", "2024-01-30", 2),
+		record("A", "2024-01-31", 3),
+		record("A", "2024-02-01", 4),
+	})
+	is.NoErr(err)
+	is.Equal(n, 4)
+	// only the latest file of every collection is still open
+	is.Equal(len(w.files), 2)
+	is.Equal(w.series, map[string]string{"A_": "A_2024-02-01.csv", "B_": "B_2024-01-30.csv"})
+	is.NoErr(w.close())
+
+	for name, want := range map[string]string{
+		"A_2024-01-30.csv": "PLAYER_ID\n1\n",
+		"A_2024-01-31.csv": "PLAYER_ID\n3\n",
+		"A_2024-02-01.csv": "PLAYER_ID\n4\n",
+		"B_2024-01-30.csv": "PLAYER_ID\n2\n",
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		is.NoErr(err)
+		is.Equal(string(got), want)
+	}
+}
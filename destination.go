@@ -4,27 +4,227 @@ package nbastats
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	sdk "github.com/conduitio/conduit-connector-sdk"
 )
 
+const (
+	// defaultBatchSize is the default of sdk.batch.size.
+	defaultBatchSize = 1000
+	// defaultBatchDelay is the default of sdk.batch.delay.
+	defaultBatchDelay = time.Second
+)
+
 type Destination struct {
 	sdk.UnimplementedDestination
 
 	config DestinationConfig
+	writer recordWriter
 }
 
 type DestinationConfig struct {
-	// Config includes parameters that are the same in the source and destination.
-	Config
-	// DestinationConfigParam must be either yes or no (defaults to yes).
-	DestinationConfigParam string `validate:"inclusion=yes|no" default:"yes"`
+	// Format of the written files: "csv" writes the rows of one collection
+	// and season to one CSV file, "parquet" writes typed Parquet files in
+	// Hive style partitions, "sqlite" upserts the rows into a SQLite
+	// database with one table per collection, "jsonl" appends every record
+	// to rotated JSON Lines files, "leaderboard" keeps the latest rows in
+	// memory and serves sorted leaderboards over HTTP, "prometheus" exposes
+	// the numeric fields of the latest rows as Prometheus gauges, "report"
+	// renders the latest snapshots through a template into report files,
+	// "webhook" posts the records as JSON to a URL.
+	Format string `json:"format" validate:"inclusion=csv|parquet|sqlite|jsonl|leaderboard|prometheus|report|webhook" default:"csv"`
+	// Path is the directory the files are written to, or the database file
+	// with format sqlite. With format leaderboard, it is the optional file
+	// the leaderboards are persisted to, formats prometheus and webhook
+	// ignore it.
+	Path string `json:"path"`
+	// WriteMode selects how records are written: "append" appends them to
+	// the output, "upsert" updates the row with the key of the record,
+	// "snapshot" atomically replaces the previous snapshot of a leaderboard
+	// once all of its rows were written. The formats support the modes
+	// listed in the README, empty selects the first mode supported by the
+	// format.
+	WriteMode string `json:"writeMode" validate:"inclusion=append|upsert|snapshot"`
+	// NameTemplate is a Go template of the names of the written files,
+	// without extension, or of the tables with format sqlite. Empty selects
+	// the default of the format and write mode.
+	NameTemplate string `json:"nameTemplate"`
+	// Parquet configures the files written with format parquet.
+	Parquet ParquetConfig `json:"parquet"`
+	// JSONL configures the files written with format jsonl.
+	JSONL JSONLConfig `json:"jsonl"`
+	// Leaderboard configures the leaderboards served with format
+	// leaderboard.
+	Leaderboard LeaderboardConfig `json:"leaderboard"`
+	// Prometheus configures the metrics exposed with format prometheus.
+	Prometheus PrometheusConfig `json:"prometheus"`
+	// Report configures the reports rendered with format report.
+	Report ReportConfig `json:"report"`
+	// Webhook configures the requests sent with format webhook.
+	Webhook WebhookConfig `json:"webhook"`
+}
+
+const (
+	// writeModeAppend appends records to the output.
+	writeModeAppend = "append"
+	// writeModeUpsert inserts or updates the row with the key of a record.
+	writeModeUpsert = "upsert"
+	// writeModeSnapshot replaces the previous snapshot of the rows of a
+	// response once all rows were written.
+	writeModeSnapshot = "snapshot"
+)
+
+// writeModes are the write modes supported by the formats, the first one is
+// the default.
+var writeModes = map[string][]string{
+	"csv":         {writeModeAppend, writeModeSnapshot},
+	"parquet":     {writeModeAppend, writeModeSnapshot},
+	"sqlite":      {writeModeUpsert, writeModeSnapshot},
+	"jsonl":       {writeModeAppend, writeModeSnapshot},
+	"leaderboard": {writeModeUpsert, writeModeSnapshot},
+	"prometheus":  {writeModeUpsert},
+	"report":      {writeModeSnapshot},
+	"webhook":     {writeModeAppend},
+}
+
+// validate checks the combination of parameters, the SDK validates single
+// parameters.
+func (c DestinationConfig) validate() error {
+	switch c.format() {
+	case "leaderboard", "prometheus", "webhook":
+	default:
+		if c.Path == "" {
+			return fmt.Errorf("format %s requires a path", c.format())
+		}
+	}
+	if c.format() == "leaderboard" {
+		_, err := c.Leaderboard.leaderboardColumns()
+		if err != nil {
+			return err
+		}
+	}
+	if c.format() == "report" && c.Report.Template == "" {
+		return errors.New("format report requires a report template")
+	}
+	if c.format() == "webhook" {
+		err := c.Webhook.validate()
+		if err != nil {
+			return err
+		}
+	}
+	modes, ok := writeModes[c.format()]
+	if !ok {
+		return fmt.Errorf("unsupported format %q", c.Format)
+	}
+	if !c.supportsWriteMode(modes) {
+		return fmt.Errorf("format %s doesn't support write mode %q, supported modes: %s",
+			c.format(), c.WriteMode, strings.Join(modes, ", "))
+	}
+	names, err := c.names()
+	if err != nil {
+		return err
+	}
+	if c.format() == "csv" && c.writeMode() == writeModeAppend && (names.uses("Timestamp") || names.uses("Seq")) {
+		// rows are appended to the file with the rendered name, a name
+		// changing with every record would start a file per record
+		return errors.New("format csv appends to files and doesn't support .Timestamp or .Seq in the name template")
+	}
+	return nil
+}
+
+func (c DestinationConfig) supportsWriteMode(modes []string) bool {
+	if c.WriteMode == "" {
+		return true
+	}
+	for _, mode := range modes {
+		if mode == c.WriteMode {
+			return true
+		}
+	}
+	return false
+}
+
+// format returns the configured format, csv if none is configured.
+func (c DestinationConfig) format() string {
+	if c.Format == "" {
+		return "csv"
+	}
+	return c.Format
+}
+
+// writeMode returns the configured write mode, or the default mode of the
+// format.
+func (c DestinationConfig) writeMode() string {
+	if c.WriteMode != "" {
+		return c.WriteMode
+	}
+	return writeModes[c.format()][0]
+}
+
+// names returns the name template of the written files or tables.
+func (c DestinationConfig) names() (nameTemplate, error) {
+	if c.writeMode() == writeModeSnapshot {
+		return parseNameTemplate(c.NameTemplate, defaultSnapshotNameTemplates[c.format()])
+	}
+	return parseNameTemplate(c.NameTemplate, defaultNameTemplates[c.format()])
+}
+
+// newRecordWriter returns the writer of the configured format and write
+// mode.
+func (c DestinationConfig) newRecordWriter() (recordWriter, error) {
+	names, err := c.names()
+	if err != nil {
+		return nil, err
+	}
+	snapshot := c.writeMode() == writeModeSnapshot
+	switch c.format() {
+	case "csv":
+		if snapshot {
+			return newCSVSnapshotWriter(c.Path, names)
+		}
+		return newCSVWriter(c.Path, names)
+	case "parquet":
+		if snapshot {
+			return newParquetSnapshotWriter(c.Path, names)
+		}
+		return newParquetWriter(c.Path, c.Parquet, names)
+	case "sqlite":
+		return newSQLiteWriter(c.Path, names, snapshot)
+	case "jsonl":
+		if snapshot {
+			return newJSONLSnapshotWriter(c.Path, c.JSONL, names)
+		}
+		return newJSONLWriter(c.Path, c.JSONL, names)
+	case "leaderboard":
+		return newLeaderboardWriter(c.Path, c.Leaderboard, names, snapshot)
+	case "prometheus":
+		return newMetricsWriter(c.Prometheus)
+	case "report":
+		return newReportWriter(c.Path, c.Report, names)
+	case "webhook":
+		return newWebhookWriter(c.Webhook), nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q", c.Format)
+	}
 }
 
 func NewDestination() sdk.Destination {
-	// Create Destination and wrap it in the default middleware.
-	return sdk.DestinationWithMiddleware(&Destination{}, sdk.DefaultDestinationMiddleware()...)
+	// Create Destination and wrap it in the default middleware. Records are
+	// batched by default, the writers flush their files once per batch.
+	middleware := sdk.DefaultDestinationMiddleware()
+	for i, m := range middleware {
+		if _, ok := m.(sdk.DestinationWithBatch); ok {
+			middleware[i] = sdk.DestinationWithBatch{
+				DefaultBatchSize:  defaultBatchSize,
+				DefaultBatchDelay: defaultBatchDelay,
+			}
+		}
+	}
+	return sdk.DestinationWithMiddleware(&Destination{}, middleware...)
 }
 
 func (d *Destination) Parameters() map[string]sdk.Parameter {
@@ -49,6 +249,10 @@ func (d *Destination) Configure(ctx context.Context, cfg map[string]string) erro
 	if err != nil {
 		return fmt.Errorf("invalid config: %w", err)
 	}
+	err = d.config.validate()
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
 	return nil
 }
 
@@ -56,6 +260,11 @@ func (d *Destination) Open(ctx context.Context) error {
 	// Open is called after Configure to signal the plugin it can prepare to
 	// start writing records. If needed, the plugin should open connections in
 	// this function.
+	var err error
+	d.writer, err = d.config.newRecordWriter()
+	if err != nil {
+		return fmt.Errorf("failed to open %s writer: %w", d.config.format(), err)
+	}
 	return nil
 }
 
@@ -64,12 +273,15 @@ func (d *Destination) Write(ctx context.Context, records []sdk.Record) (int, err
 	// caching. It should return the number of records written from r
 	// (0 <= n <= len(r)) and any error encountered that caused the write to
 	// stop early. Write must return a non-nil error if it returns n < len(r).
-	return 0, nil
+	return d.writer.write(ctx, records)
 }
 
 func (d *Destination) Teardown(ctx context.Context) error {
 	// Teardown signals to the plugin that all records were written and there
 	// will be no more calls to any other function. After Teardown returns, the
 	// plugin should be ready for a graceful shutdown.
-	return nil
+	if d.writer == nil {
+		return nil
+	}
+	return d.writer.close()
 }
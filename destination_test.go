@@ -1,10 +1,27 @@
 package nbastats_test
 
 import (
+	"compress/gzip"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
+	"time"
 
 	nbastats "github.com/William-Hill/conduit-connector-nba-stats"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/klauspost/compress/zstd"
 	"github.com/matryer/is"
 )
 
@@ -14,3 +31,813 @@ func TestTeardown_NoOpen(t *testing.T) {
 	err := con.Teardown(context.Background())
 	is.NoErr(err)
 }
+
+func TestConfigureDestination(t *testing.T) {
+	testCases := []struct {
+		name    string
+		cfg     map[string]string
+		wantErr bool
+	}{
+		{
+			name: "default write mode",
+			cfg:  map[string]string{"format": "sqlite", "path": "stats.db"},
+		},
+		{
+			name: "append to files",
+			cfg:  map[string]string{"format": "jsonl", "path": "out", "writeMode": "append"},
+		},
+		{
+			name:    "upsert to files",
+			cfg:     map[string]string{"format": "csv", "path": "out", "writeMode": "upsert"},
+			wantErr: true,
+		},
+		{
+			name: "name template",
+			cfg:  map[string]string{"format": "csv", "path": "out", "nameTemplate": "{{.League}}_{{.Collection}}"},
+		},
+		{
+			name:    "name template with unknown field",
+			cfg:     map[string]string{"format": "csv", "path": "out", "nameTemplate": "{{.Team}}"},
+			wantErr: true,
+		},
+		{
+			name: "csv name template with date",
+			cfg:  map[string]string{"format": "csv", "path": "out", "nameTemplate": "{{.Collection}}_{{.Date}}"},
+		},
+		{
+			name:    "csv name template with timestamp",
+			cfg:     map[string]string{"format": "csv", "path": "out", "nameTemplate": "{{.Collection}}{{with .Season}}_{{$.Timestamp}}{{end}}"},
+			wantErr: true,
+		},
+		{
+			name:    "csv name template with sequence number",
+			cfg:     map[string]string{"format": "csv", "path": "out", "nameTemplate": "{{.Collection}}-{{.Seq}}"},
+			wantErr: true,
+		},
+		{
+			name: "csv snapshot name template with timestamp",
+			cfg:  map[string]string{"format": "csv", "path": "out", "writeMode": "snapshot", "nameTemplate": "{{.Collection}}-{{.Timestamp}}"},
+		},
+		{
+			name:    "report without template",
+			cfg:     map[string]string{"format": "report", "path": "out"},
+			wantErr: true,
+		},
+		{
+			name:    "webhook without url",
+			cfg:     map[string]string{"format": "webhook", "webhook.maxRecords": "100"},
+			wantErr: true,
+		},
+		{
+			name:    "missing path",
+			cfg:     map[string]string{"format": "csv"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			con := nbastats.NewDestination()
+			err := con.Configure(context.Background(), tc.cfg)
+			is.Equal(err != nil, tc.wantErr)
+		})
+	}
+}
+
+func TestWrite_CSV(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	con := nbastats.NewDestination()
+	is.NoErr(con.Configure(ctx, map[string]string{"format": "csv", "path": dir}))
+	is.NoErr(con.Open(ctx))
+
+	metadata := sdk.Metadata{"opencdc.collection": "LeagueDashPtStats", "nbastats.season": "2023-24"}
+	records := []sdk.Record{
+		sdk.Util.Source.NewRecordCreate(nil, metadata, nil, sdk.StructuredData{"PLAYER_ID": 201939.0, "TEAM_ID": 1610612744.0, "DIST_MILES": 2.5}),
+		sdk.Util.Source.NewRecordCreate(nil, metadata, nil, sdk.StructuredData{"PLAYER_ID": 2544.0, "TEAM_ID": 1610612747.0, "DIST_MILES": 2.25, "AVG_SPEED": 4.1}),
+	}
+	n, err := con.Write(ctx, records)
+	is.NoErr(err)
+	is.Equal(n, len(records))
+	is.NoErr(con.Teardown(ctx))
+
+	got, err := os.ReadFile(filepath.Join(dir, "LeagueDashPtStats_2023-24.csv"))
+	is.NoErr(err)
+	is.Equal(string(got), "PLAYER_ID,TEAM_ID,DIST_MILES,AVG_SPEED\n"+
+		"201939,1610612744,2.5,\n"+
+		"2544,1610612747,2.25,4.1\n")
+}
+
+// snapshotRow returns the record of a row of the given snapshot, fetched
+// on the given date.
+func snapshotRow(snapshot, date string, index int, complete bool, playerID, dist float64) sdk.Record {
+	metadata := sdk.Metadata{
+		"opencdc.collection":    "LeagueDashPtStats",
+		"nbastats.league":       "nba",
+		"nbastats.season":       "2023-24",
+		"nbastats.season_type":  "Regular Season",
+		"nbastats.measure_type": "SpeedDistance",
+		"nbastats.per_mode":     "PerGame",
+		"nbastats.date":         date,
+		"nbastats.snapshot":     snapshot,
+		"nbastats.snapshot.row": strconv.Itoa(index),
+	}
+	if complete {
+		metadata["nbastats.snapshot.complete"] = "true"
+	}
+	return sdk.Util.Source.NewRecordCreate(nil, metadata, sdk.StructuredData{"PLAYER_ID": playerID}, sdk.StructuredData{"PLAYER_ID": playerID, "DIST_MILES": dist})
+}
+
+func TestWrite_CSVSnapshot(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	con := nbastats.NewDestination()
+	is.NoErr(con.Configure(ctx, map[string]string{"format": "csv", "path": dir, "writeMode": "snapshot"}))
+	is.NoErr(con.Open(ctx))
+	defer func() { is.NoErr(con.Teardown(ctx)) }()
+
+	row := func(snapshot string, index int, complete bool, playerID, dist float64) sdk.Record {
+		return snapshotRow(snapshot, "2024-01-31", index, complete, playerID, dist)
+	}
+	path := filepath.Join(dir, "LeagueDashPtStats_2023-24_SpeedDistance_PerGame.csv")
+
+	_, err := con.Write(ctx, []sdk.Record{row("a", 0, false, 1, 2.5)})
+	is.NoErr(err)
+	_, err = os.Stat(path)
+	is.True(errors.Is(err, os.ErrNotExist)) // incomplete snapshots are invisible
+	_, err = con.Write(ctx, []sdk.Record{row("a", 1, true, 2, 2.1)})
+	is.NoErr(err)
+	got, err := os.ReadFile(path)
+	is.NoErr(err)
+	is.Equal(string(got), "PLAYER_ID,DIST_MILES\n1,2.5\n2,2.1\n")
+
+	_, err = con.Write(ctx, []sdk.Record{row("b", 0, false, 3, 1.9)})
+	is.NoErr(err)
+	got, err = os.ReadFile(path)
+	is.NoErr(err)
+	is.Equal(string(got), "PLAYER_ID,DIST_MILES\n1,2.5\n2,2.1\n")
+	_, err = con.Write(ctx, []sdk.Record{row("b", 1, true, 4, 1.8)})
+	is.NoErr(err)
+	got, err = os.ReadFile(path)
+	is.NoErr(err)
+	is.Equal(string(got), "PLAYER_ID,DIST_MILES\n3,1.9\n4,1.8\n")
+}
+
+func TestWrite_ParquetSnapshot(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	con := nbastats.NewDestination()
+	is.NoErr(con.Configure(ctx, map[string]string{"format": "parquet", "path": dir, "writeMode": "snapshot"}))
+	is.NoErr(con.Open(ctx))
+	defer func() { is.NoErr(con.Teardown(ctx)) }()
+
+	partition := filepath.Join(dir, "LeagueDashPtStats", "league=nba", "season=2023-24",
+		"season_type=Regular Season", "measure_type=SpeedDistance")
+	path := filepath.Join(partition, "snapshot_PerGame.parquet")
+	// files contains the names of all files in the partition, including
+	// hidden ones
+	files := func() []string {
+		entries, err := os.ReadDir(partition)
+		is.NoErr(err)
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		return names
+	}
+
+	_, err := con.Write(ctx, []sdk.Record{snapshotRow("a", "2024-01-31", 0, false, 1, 2.5)})
+	is.NoErr(err)
+	_, err = os.Stat(path)
+	is.True(errors.Is(err, os.ErrNotExist)) // incomplete snapshots are invisible
+	_, err = con.Write(ctx, []sdk.Record{snapshotRow("a", "2024-01-31", 1, true, 2, 2.1)})
+	is.NoErr(err)
+	is.Equal(files(), []string{"snapshot_PerGame.parquet"})
+	first, err := os.ReadFile(path)
+	is.NoErr(err)
+
+	// the snapshot of the next day replaces the previous one, it isn't
+	// written to a partition of its own
+	_, err = con.Write(ctx, []sdk.Record{
+		snapshotRow("b", "2024-02-01", 0, false, 3, 1.9),
+		snapshotRow("b", "2024-02-01", 1, false, 4, 1.8),
+		snapshotRow("b", "2024-02-01", 2, true, 5, 1.7),
+	})
+	is.NoErr(err)
+	is.Equal(files(), []string{"snapshot_PerGame.parquet"})
+	second, err := os.ReadFile(path)
+	is.NoErr(err)
+	is.True(len(second) > len(first))
+	is.Equal(string(second[len(second)-4:]), "PAR1") // the file is complete
+}
+
+func TestWrite_SQLiteSnapshot(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "stats.db")
+
+	con := nbastats.NewDestination()
+	is.NoErr(con.Configure(ctx, map[string]string{"format": "sqlite", "path": path, "writeMode": "snapshot"}))
+	is.NoErr(con.Open(ctx))
+	defer func() { is.NoErr(con.Teardown(ctx)) }()
+
+	db, err := sql.Open("sqlite", path)
+	is.NoErr(err)
+	defer db.Close()
+	table := `"LeagueDashPtStats_2023-24_SpeedDistance_PerGame"`
+	// players returns the players in the table
+	players := func(table string) []int {
+		rows, err := db.Query(`SELECT PLAYER_ID FROM ` + table + ` ORDER BY PLAYER_ID`)
+		is.NoErr(err)
+		defer rows.Close()
+		var ids []int
+		for rows.Next() {
+			var id int
+			is.NoErr(rows.Scan(&id))
+			ids = append(ids, id)
+		}
+		is.NoErr(rows.Err())
+		return ids
+	}
+
+	_, err = con.Write(ctx, []sdk.Record{
+		snapshotRow("a", "2024-01-31", 0, false, 1, 2.5),
+		snapshotRow("a", "2024-01-31", 1, true, 2, 2.1),
+	})
+	is.NoErr(err)
+	is.Equal(players(table), []int{1, 2})
+
+	// the rows of an incomplete snapshot are staged, the table is unchanged
+	_, err = con.Write(ctx, []sdk.Record{snapshotRow("b", "2024-02-01", 0, false, 3, 1.9)})
+	is.NoErr(err)
+	is.Equal(players(table), []int{1, 2})
+	is.Equal(players(`"LeagueDashPtStats_2023-24_SpeedDistance_PerGame_staging"`), []int{3})
+
+	// the last row replaces the table with the staging table
+	_, err = con.Write(ctx, []sdk.Record{snapshotRow("b", "2024-02-01", 1, true, 4, 1.8)})
+	is.NoErr(err)
+	is.Equal(players(table), []int{3, 4})
+	var staging int
+	is.NoErr(db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'LeagueDashPtStats_2023-24_SpeedDistance_PerGame_staging'`).Scan(&staging))
+	is.Equal(staging, 0)
+}
+
+func TestWrite_ParquetPartitions(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	con := nbastats.NewDestination()
+	is.NoErr(con.Configure(ctx, map[string]string{"format": "parquet", "path": dir}))
+	is.NoErr(con.Open(ctx))
+
+	metadata := sdk.Metadata{
+		"opencdc.collection":    "LeagueDashPtStats",
+		"nbastats.league":       "nba",
+		"nbastats.season":       "2023-24",
+		"nbastats.season_type":  "Regular Season",
+		"nbastats.measure_type": "SpeedDistance",
+		"nbastats.date":         "2024-01-31",
+	}
+	partition := filepath.Join(dir, "LeagueDashPtStats", "league=nba", "season=2023-24",
+		"season_type=Regular Season", "measure_type=SpeedDistance")
+	// files contains the names of all files in a date partition, including
+	// hidden ones
+	files := func(date string) []string {
+		entries, err := os.ReadDir(filepath.Join(partition, "date="+date))
+		is.NoErr(err)
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		return names
+	}
+
+	n, err := con.Write(ctx, []sdk.Record{
+		sdk.Util.Source.NewRecordCreate(nil, metadata, nil, sdk.StructuredData{"PLAYER_ID": 201939.0, "DIST_MILES": 2.5}),
+	})
+	is.NoErr(err)
+	is.Equal(n, 1)
+	// acknowledged rows are in complete files
+	day1 := files("2024-01-31")
+	is.Equal(len(day1), 1)
+	is.Equal(filepath.Ext(day1[0]), ".parquet")
+
+	next := sdk.Metadata{}
+	for k, v := range metadata {
+		next[k] = v
+	}
+	next["nbastats.date"] = "2024-02-01"
+	n, err = con.Write(ctx, []sdk.Record{
+		sdk.Util.Source.NewRecordCreate(nil, next, nil, sdk.StructuredData{"PLAYER_ID": 201939.0, "DIST_MILES": 2.7}),
+		sdk.Util.Source.NewRecordCreate(nil, next, nil, sdk.StructuredData{"PLAYER_ID": 2544.0, "DIST_MILES": 2.2}),
+	})
+	is.NoErr(err)
+	is.Equal(n, 2)
+	is.Equal(files("2024-01-31"), day1)
+	day2 := files("2024-02-01")
+	is.Equal(len(day2), 1)
+	is.Equal(filepath.Ext(day2[0]), ".parquet")
+	is.NoErr(con.Teardown(ctx))
+}
+
+func TestWrite_SQLiteUpsertAndDelete(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "stats.db")
+
+	con := nbastats.NewDestination()
+	is.NoErr(con.Configure(ctx, map[string]string{"format": "sqlite", "path": path}))
+	is.NoErr(con.Open(ctx))
+
+	metadata := sdk.Metadata{"opencdc.collection": "LeagueDashPtStats"}
+	records := []sdk.Record{
+		sdk.Util.Source.NewRecordCreate(nil, metadata, sdk.StructuredData{"PLAYER_ID": 1}, sdk.StructuredData{"PLAYER_ID": 1.0, "DIST_MILES": 2.5}),
+		sdk.Util.Source.NewRecordCreate(nil, metadata, sdk.StructuredData{"PLAYER_ID": 2}, sdk.StructuredData{"PLAYER_ID": 2.0, "DIST_MILES": 2.1}),
+		sdk.Util.Source.NewRecordUpdate(nil, metadata, sdk.StructuredData{"PLAYER_ID": 1}, nil, sdk.StructuredData{"PLAYER_ID": 1.0, "DIST_MILES": 2.7, "AVG_SPEED": 4.2}),
+		sdk.Util.Source.NewRecordDelete(nil, metadata, sdk.StructuredData{"PLAYER_ID": 2}),
+	}
+	n, err := con.Write(ctx, records)
+	is.NoErr(err)
+	is.Equal(n, len(records))
+	is.NoErr(con.Teardown(ctx))
+
+	db, err := sql.Open("sqlite", path)
+	is.NoErr(err)
+	defer db.Close()
+	var (
+		count    int
+		dist     float64
+		avgSpeed float64
+	)
+	is.NoErr(db.QueryRow(`SELECT COUNT(*) FROM "LeagueDashPtStats"`).Scan(&count))
+	is.Equal(count, 1)
+	is.NoErr(db.QueryRow(`SELECT DIST_MILES, AVG_SPEED FROM "LeagueDashPtStats" WHERE PLAYER_ID = 1`).Scan(&dist, &avgSpeed))
+	is.Equal(dist, 2.7)
+	is.Equal(avgSpeed, 4.2)
+}
+
+func TestWrite_JSONLGzip(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	con := nbastats.NewDestination()
+	is.NoErr(con.Configure(ctx, map[string]string{
+		"format":            "jsonl",
+		"path":              dir,
+		"jsonl.content":     "payload",
+		"jsonl.compression": "gzip",
+	}))
+	is.NoErr(con.Open(ctx))
+
+	metadata := sdk.Metadata{"opencdc.collection": "LeagueDashPtStats"}
+	n, err := con.Write(ctx, []sdk.Record{
+		sdk.Util.Source.NewRecordCreate(nil, metadata, nil, sdk.StructuredData{"PLAYER_ID": 201939.0}),
+		sdk.Util.Source.NewRecordCreate(nil, metadata, nil, sdk.RawData(`{"PLAYER_ID":2544}`)),
+		sdk.Util.Source.NewRecordDelete(nil, metadata, sdk.RawData("2544")),
+	})
+	is.NoErr(err)
+	is.Equal(n, 3)
+
+	// files are only visible once complete
+	files, err := filepath.Glob(filepath.Join(dir, "*.jsonl.gz"))
+	is.NoErr(err)
+	is.Equal(len(files), 0)
+	is.NoErr(con.Teardown(ctx))
+
+	files, err = filepath.Glob(filepath.Join(dir, "LeagueDashPtStats-*.jsonl.gz"))
+	is.NoErr(err)
+	is.Equal(len(files), 1)
+	f, err := os.Open(files[0])
+	is.NoErr(err)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	is.NoErr(err)
+	got, err := io.ReadAll(zr)
+	is.NoErr(err)
+	is.Equal(string(got), "{\"PLAYER_ID\":201939}\n{\"PLAYER_ID\":2544}\n")
+}
+
+func TestWrite_JSONLRotation(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	con := nbastats.NewDestination()
+	is.NoErr(con.Configure(ctx, map[string]string{
+		"format":               "jsonl",
+		"path":                 dir,
+		"nameTemplate":         "{{.Collection}}_{{.Season}}-{{.Seq}}",
+		"jsonl.content":        "payload",
+		"jsonl.compression":    "none",
+		"jsonl.maxFileSize":    "0",
+		"jsonl.rotateInterval": "100ms",
+	}))
+	is.NoErr(con.Open(ctx))
+
+	record := func(season string, playerID float64) sdk.Record {
+		metadata := sdk.Metadata{"opencdc.collection": "LeagueDashPtStats", "nbastats.season": season}
+		return sdk.Util.Source.NewRecordCreate(nil, metadata, nil, sdk.StructuredData{"PLAYER_ID": playerID})
+	}
+	n, err := con.Write(ctx, []sdk.Record{
+		record("2022-23", 1),
+		record("2023-24", 2),
+		record("2022-23", 3),
+	})
+	is.NoErr(err)
+	is.Equal(n, 3)
+
+	// files are completed once they expire, without another write
+	var files []string
+	for deadline := time.Now().Add(5 * time.Second); len(files) < 2 && time.Now().Before(deadline); {
+		time.Sleep(20 * time.Millisecond)
+		files, err = filepath.Glob(filepath.Join(dir, "*.jsonl"))
+		is.NoErr(err)
+	}
+	is.Equal(len(files), 2)
+	got, err := os.ReadFile(filepath.Join(dir, "LeagueDashPtStats_2022-23-00001.jsonl"))
+	is.NoErr(err)
+	is.Equal(string(got), "{\"PLAYER_ID\":1}\n{\"PLAYER_ID\":3}\n")
+	got, err = os.ReadFile(filepath.Join(dir, "LeagueDashPtStats_2023-24-00002.jsonl"))
+	is.NoErr(err)
+	is.Equal(string(got), "{\"PLAYER_ID\":2}\n")
+
+	// the next record starts a new file
+	n, err = con.Write(ctx, []sdk.Record{record("2022-23", 4)})
+	is.NoErr(err)
+	is.Equal(n, 1)
+	is.NoErr(con.Teardown(ctx))
+	got, err = os.ReadFile(filepath.Join(dir, "LeagueDashPtStats_2022-23-00003.jsonl"))
+	is.NoErr(err)
+	is.Equal(string(got), "{\"PLAYER_ID\":4}\n")
+}
+
+func TestWrite_JSONLRecovery(t *testing.T) {
+	for _, compression := range []string{"none", "gzip", "zstd"} {
+		t.Run(compression, func(t *testing.T) {
+			is := is.New(t)
+			ctx := context.Background()
+			dir := t.TempDir()
+			cfg := map[string]string{
+				"format":               "jsonl",
+				"path":                 dir,
+				"nameTemplate":         "{{.Collection}}",
+				"jsonl.content":        "payload",
+				"jsonl.compression":    compression,
+				"jsonl.rotateInterval": "0",
+			}
+			record := func(playerID float64) sdk.Record {
+				metadata := sdk.Metadata{"opencdc.collection": "LeagueDashPtStats"}
+				return sdk.Util.Source.NewRecordCreate(nil, metadata, nil, sdk.StructuredData{"PLAYER_ID": playerID})
+			}
+
+			// the first destination crashes after acknowledging two batches
+			crashed := nbastats.NewDestination()
+			is.NoErr(crashed.Configure(ctx, cfg))
+			is.NoErr(crashed.Open(ctx))
+			for _, id := range []float64{1, 2} {
+				n, err := crashed.Write(ctx, []sdk.Record{record(id)})
+				is.NoErr(err)
+				is.Equal(n, 1)
+			}
+			inProgress, err := filepath.Glob(filepath.Join(dir, ".*.inprogress"))
+			is.NoErr(err)
+			is.Equal(len(inProgress), 1)
+			if compression == "none" {
+				// a line that was only partially written
+				f, err := os.OpenFile(inProgress[0], os.O_APPEND|os.O_WRONLY, 0)
+				is.NoErr(err)
+				_, err = f.WriteString(`{"PLAYER_`)
+				is.NoErr(err)
+				is.NoErr(f.Close())
+			}
+
+			// the acknowledged records are recovered by the next destination
+			con := nbastats.NewDestination()
+			is.NoErr(con.Configure(ctx, cfg))
+			is.NoErr(con.Open(ctx))
+			is.NoErr(con.Teardown(ctx))
+			inProgress, err = filepath.Glob(filepath.Join(dir, ".*"))
+			is.NoErr(err)
+			is.Equal(len(inProgress), 0)
+			files, err := filepath.Glob(filepath.Join(dir, "LeagueDashPtStats.jsonl*"))
+			is.NoErr(err)
+			is.Equal(len(files), 1)
+
+			f, err := os.Open(files[0])
+			is.NoErr(err)
+			defer f.Close()
+			var r io.Reader = f
+			switch compression {
+			case "gzip":
+				r, err = gzip.NewReader(f)
+				is.NoErr(err)
+			case "zstd":
+				d, err := zstd.NewReader(f)
+				is.NoErr(err)
+				defer d.Close()
+				r = d
+			}
+			got, err := io.ReadAll(r)
+			is.NoErr(err)
+			is.Equal(string(got), "{\"PLAYER_ID\":1}\n{\"PLAYER_ID\":2}\n")
+		})
+	}
+}
+
+func TestWrite_Leaderboard(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "leaderboards.json")
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	is.NoErr(err)
+	addr := ln.Addr().String()
+	is.NoErr(ln.Close())
+	cfg := map[string]string{
+		"format":              "leaderboard",
+		"path":                path,
+		"leaderboard.address": addr,
+		"leaderboard.columns": "DIST_MILES,AVG_SPEED:asc",
+		"leaderboard.limit":   "10",
+	}
+
+	con := nbastats.NewDestination()
+	is.NoErr(con.Configure(ctx, cfg))
+	is.NoErr(con.Open(ctx))
+	metadata := sdk.Metadata{"opencdc.collection": "LeagueDashPtStats"}
+	row := func(playerID int, team string, dist, speed float64) sdk.Record {
+		return sdk.Util.Source.NewRecordCreate(nil, metadata, sdk.StructuredData{"PLAYER_ID": playerID},
+			sdk.StructuredData{"PLAYER_ID": float64(playerID), "TEAM_ABBREVIATION": team, "DIST_MILES": dist, "AVG_SPEED": speed})
+	}
+	n, err := con.Write(ctx, []sdk.Record{
+		row(201939, "GSW", 2.5, 4.5),
+		row(2544, "LAL", 2.1, 4.1),
+		row(203076, "LAL", 2.3, 4.3),
+		sdk.Util.Source.NewRecordDelete(nil, metadata, sdk.StructuredData{"PLAYER_ID": 203076}),
+		row(1629029, "DAL", 2.7, 4.0),
+	})
+	is.NoErr(err)
+	is.Equal(n, 5)
+
+	get := func(path string, v interface{}) int {
+		resp, err := http.Get("http://" + addr + path)
+		is.NoErr(err)
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			is.NoErr(json.NewDecoder(resp.Body).Decode(v))
+		}
+		return resp.StatusCode
+	}
+	type leaderboard struct {
+		Rows []struct {
+			Rank int                    `json:"rank"`
+			Row  map[string]interface{} `json:"row"`
+		} `json:"rows"`
+	}
+	var top leaderboard
+	is.Equal(get("/boards/LeagueDashPtStats/DIST_MILES?limit=2", &top), http.StatusOK)
+	is.Equal(len(top.Rows), 2)
+	is.Equal(top.Rows[0].Row["PLAYER_ID"], 1629029.0)
+	is.Equal(top.Rows[1].Row["PLAYER_ID"], 201939.0)
+
+	var team leaderboard
+	is.Equal(get("/boards/LeagueDashPtStats/DIST_MILES?team=lal", &team), http.StatusOK)
+	is.Equal(len(team.Rows), 1)
+	is.Equal(team.Rows[0].Rank, 3)
+
+	var player struct {
+		Boards []struct {
+			Ranks map[string]int `json:"ranks"`
+		} `json:"boards"`
+	}
+	is.Equal(get("/players/2544", &player), http.StatusOK)
+	is.Equal(len(player.Boards), 1)
+	is.Equal(player.Boards[0].Ranks, map[string]int{"DIST_MILES": 3, "AVG_SPEED": 2})
+	is.Equal(get("/players/203076", nil), http.StatusNotFound)
+	is.NoErr(con.Teardown(ctx))
+
+	// the leaderboards are restored from the persisted file
+	con = nbastats.NewDestination()
+	is.NoErr(con.Configure(ctx, cfg))
+	is.NoErr(con.Open(ctx))
+	defer func() { is.NoErr(con.Teardown(ctx)) }()
+	var restored leaderboard
+	is.Equal(get("/boards/LeagueDashPtStats/AVG_SPEED", &restored), http.StatusOK)
+	is.Equal(len(restored.Rows), 3)
+	is.Equal(restored.Rows[0].Row["PLAYER_ID"], 1629029.0)
+}
+
+func TestWrite_Prometheus(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	is.NoErr(err)
+	addr := ln.Addr().String()
+	is.NoErr(ln.Close())
+
+	con := nbastats.NewDestination()
+	is.NoErr(con.Configure(ctx, map[string]string{
+		"format":             "prometheus",
+		"prometheus.address": addr,
+		"prometheus.path":    "/metrics",
+		"prometheus.prefix":  "nba_",
+	}))
+	is.NoErr(con.Open(ctx))
+	defer func() { is.NoErr(con.Teardown(ctx)) }()
+
+	metadata := sdk.Metadata{
+		"opencdc.collection":    "LeagueDashPtStats",
+		"nbastats.season":       "2023-24",
+		"nbastats.measure_type": "SpeedDistance",
+		"nbastats.per_mode":     "PerGame",
+	}
+	n, err := con.Write(ctx, []sdk.Record{
+		sdk.Util.Source.NewRecordCreate(nil, metadata, sdk.StructuredData{"PLAYER_ID": 201939}, sdk.StructuredData{
+			"PLAYER_ID": 201939.0, "PLAYER_NAME": "Stephen Curry", "TEAM_ID": 1610612744.0, "TEAM_ABBREVIATION": "GSW",
+			"DIST_MILES": 2.5, "AVG_SPEED": 4.5,
+		}),
+		sdk.Util.Source.NewRecordCreate(nil, metadata, sdk.StructuredData{"PLAYER_ID": 2544}, sdk.StructuredData{
+			"PLAYER_ID": 2544.0, "PLAYER_NAME": "LeBron James", "TEAM_ABBREVIATION": "LAL", "DIST_MILES": 2.1,
+		}),
+		sdk.Util.Source.NewRecordDelete(nil, metadata, sdk.StructuredData{"PLAYER_ID": 2544}),
+	})
+	is.NoErr(err)
+	is.Equal(n, 3)
+
+	scrape := func() string {
+		resp, err := http.Get("http://" + addr + "/metrics")
+		is.NoErr(err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		is.NoErr(err)
+		return string(body)
+	}
+	labels := `{collection="LeagueDashPtStats",season="2023-24",measure_type="SpeedDistance",per_mode="PerGame",player_id="201939",player="Stephen Curry",team="GSW"}`
+	is.Equal(scrape(), "# HELP nba_avg_speed Latest value of the stat in the rows written to the NBA stats destination.\n"+
+		"# TYPE nba_avg_speed gauge\n"+
+		"nba_avg_speed"+labels+" 4.5\n"+
+		"# HELP nba_dist_miles Latest value of the stat in the rows written to the NBA stats destination.\n"+
+		"# TYPE nba_dist_miles gauge\n"+
+		"nba_dist_miles"+labels+" 2.5\n")
+
+	// the same key with another per mode is a separate series, a changed
+	// label replaces the series of the row
+	totals := sdk.Metadata{}
+	for k, v := range metadata {
+		totals[k] = v
+	}
+	totals["nbastats.per_mode"] = "Totals"
+	n, err = con.Write(ctx, []sdk.Record{
+		sdk.Util.Source.NewRecordCreate(nil, totals, sdk.StructuredData{"PLAYER_ID": 201939}, sdk.StructuredData{
+			"PLAYER_ID": 201939.0, "PLAYER_NAME": "Stephen Curry", "TEAM_ABBREVIATION": "GSW", "DIST_MILES": 150.2,
+		}),
+		sdk.Util.Source.NewRecordUpdate(nil, metadata, sdk.StructuredData{"PLAYER_ID": 201939}, nil, sdk.StructuredData{
+			"PLAYER_ID": 201939.0, "PLAYER_NAME": "Stephen Curry", "TEAM_ABBREVIATION": "GS", "DIST_MILES": 2.6,
+		}),
+	})
+	is.NoErr(err)
+	is.Equal(n, 2)
+	is.Equal(scrape(), "# HELP nba_dist_miles Latest value of the stat in the rows written to the NBA stats destination.\n"+
+		"# TYPE nba_dist_miles gauge\n"+
+		`nba_dist_miles{collection="LeagueDashPtStats",season="2023-24",measure_type="SpeedDistance",per_mode="PerGame",player_id="201939",player="Stephen Curry",team="GS"} 2.6`+"\n"+
+		`nba_dist_miles{collection="LeagueDashPtStats",season="2023-24",measure_type="SpeedDistance",per_mode="Totals",player_id="201939",player="Stephen Curry",team="GSW"} 150.2`+"\n")
+
+	// rows with the same label set are exposed once, until the last of
+	// them is deleted
+	n, err = con.Write(ctx, []sdk.Record{
+		sdk.Util.Source.NewRecordDelete(nil, metadata, sdk.StructuredData{"PLAYER_ID": 201939}),
+		sdk.Util.Source.NewRecordDelete(nil, totals, sdk.StructuredData{"PLAYER_ID": 201939}),
+		sdk.Util.Source.NewRecordCreate(nil, metadata, sdk.StructuredData{"row": 1}, sdk.StructuredData{"DIST_MILES": 1.0}),
+		sdk.Util.Source.NewRecordCreate(nil, metadata, sdk.StructuredData{"row": 2}, sdk.StructuredData{"DIST_MILES": 2.0}),
+		sdk.Util.Source.NewRecordDelete(nil, metadata, sdk.StructuredData{"row": 2}),
+	})
+	is.NoErr(err)
+	is.Equal(n, 5)
+	is.Equal(scrape(), "# HELP nba_dist_miles Latest value of the stat in the rows written to the NBA stats destination.\n"+
+		"# TYPE nba_dist_miles gauge\n"+
+		`nba_dist_miles{collection="LeagueDashPtStats",season="2023-24",measure_type="SpeedDistance",per_mode="PerGame",player_id="",player="",team=""} 2`+"\n")
+
+	n, err = con.Write(ctx, []sdk.Record{
+		sdk.Util.Source.NewRecordDelete(nil, metadata, sdk.StructuredData{"row": 1}),
+	})
+	is.NoErr(err)
+	is.Equal(n, 1)
+	is.Equal(scrape(), "")
+}
+
+func TestWrite_Report(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	tmpl := filepath.Join(t.TempDir(), "fastest.md.tmpl")
+	is.NoErr(os.WriteFile(tmpl, []byte(`# Fastest players {{.Date}}
+{{range top 2 (sortBy "AVG_SPEED" (.Snapshot "LeagueDashPtStats_last7d" "SpeedDistance").Rows)}}
+- {{.PLAYER_NAME}} {{.AVG_SPEED}}
+{{- end}}
+`), 0o644))
+
+	con := nbastats.NewDestination()
+	is.NoErr(con.Configure(ctx, map[string]string{"format": "report", "path": dir, "report.template": tmpl}))
+	is.NoErr(con.Open(ctx))
+	defer func() { is.NoErr(con.Teardown(ctx)) }()
+
+	row := func(index int, complete bool, player string, speed float64) sdk.Record {
+		metadata := sdk.Metadata{
+			"opencdc.collection":    "LeagueDashPtStats_last7d",
+			"nbastats.measure_type": "SpeedDistance",
+			"nbastats.date":         "2024-03-01",
+			"nbastats.snapshot":     "a",
+			"nbastats.snapshot.row": strconv.Itoa(index),
+		}
+		if complete {
+			metadata["nbastats.snapshot.complete"] = "true"
+		}
+		return sdk.Util.Source.NewRecordCreate(nil, metadata, nil, sdk.StructuredData{"PLAYER_NAME": player, "AVG_SPEED": speed})
+	}
+	n, err := con.Write(ctx, []sdk.Record{
+		row(0, false, "Stephen Curry", 4.5),
+		row(1, false, "LeBron James", 4.1),
+		row(2, true, "Luka Doncic", 4.7),
+	})
+	is.NoErr(err)
+	is.Equal(n, 3)
+
+	got, err := os.ReadFile(filepath.Join(dir, "report-2024-03-01.md"))
+	is.NoErr(err)
+	is.Equal(string(got), "# Fastest players 2024-03-01\n\n- Luka Doncic 4.7\n- Stephen Curry 4.5\n")
+}
+
+func TestWrite_Webhook(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	var requests [][]interface{}
+	var failures int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write([]byte(r.Header.Get("X-NBA-Stats-Timestamp") + "."))
+		mac.Write(body)
+		is.Equal(r.Header.Get("X-NBA-Stats-Signature"), "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+		var req struct {
+			Records []struct {
+				Payload map[string]interface{} `json:"payload"`
+			} `json:"records"`
+		}
+		is.NoErr(json.Unmarshal(body, &req))
+		switch req.Records[0].Payload["PLAYER_ID"] {
+		case 2.0:
+			// the second request fails once and is retried
+			if failures == 0 {
+				failures++
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		case 4.0:
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var ids []interface{}
+		for _, rec := range req.Records {
+			ids = append(ids, rec.Payload["PLAYER_ID"])
+		}
+		requests = append(requests, ids)
+	}))
+	defer srv.Close()
+
+	con := nbastats.NewDestination()
+	is.NoErr(con.Configure(ctx, map[string]string{
+		"format":               "webhook",
+		"webhook.url":          srv.URL,
+		"webhook.secret":       "secret",
+		"webhook.maxRecords":   "2",
+		"webhook.timeout":      "1s",
+		"webhook.maxRetries":   "2",
+		"webhook.retryBackoff": "1ms",
+	}))
+	is.NoErr(con.Open(ctx))
+	defer func() { is.NoErr(con.Teardown(ctx)) }()
+
+	records := make([]sdk.Record, 5)
+	for i := range records {
+		records[i] = sdk.Util.Source.NewRecordCreate(nil, sdk.Metadata{"opencdc.collection": "LeagueDashPtStats"},
+			sdk.StructuredData{"PLAYER_ID": i}, sdk.StructuredData{"PLAYER_ID": i})
+	}
+	n, err := con.Write(ctx, records)
+	is.True(err != nil) // the third request is rejected
+	is.Equal(n, 4)
+	is.Equal(failures, 1)
+	is.Equal(requests, [][]interface{}{{0.0, 1.0}, {2.0, 3.0}})
+}
@@ -0,0 +1,486 @@
+package nbastats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// EnrichmentConfig configures the player and team dimension tables that are
+// joined onto emitted rows.
+type EnrichmentConfig struct {
+	// Enabled joins player and team dimension columns onto every row that
+	// contains a PLAYER_ID.
+	Enabled bool `json:"enabled" default:"false"`
+	// RefreshPeriod is how often the cached dimension tables are refreshed
+	// from stats.nba.com.
+	RefreshPeriod time.Duration `json:"refreshPeriod" default:"24h"`
+	// Concurrency is the maximum number of player and team details requested
+	// at once while the dimension tables are refreshed.
+	Concurrency int `json:"concurrency" default:"4"`
+}
+
+// validate checks the enrichment parameters.
+func (c EnrichmentConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.RefreshPeriod <= 0 {
+		return fmt.Errorf("invalid enrichment refresh period %s, expected a positive duration", c.RefreshPeriod)
+	}
+	if c.Concurrency < 1 {
+		return fmt.Errorf("invalid enrichment concurrency %d, expected at least 1", c.Concurrency)
+	}
+	return nil
+}
+
+// dimensionColumns are the columns joined onto rows, in the order they are
+// appended to the result set headers.
+var dimensionColumns = []string{
+	"POSITION",
+	"HEIGHT",
+	"WEIGHT",
+	"DRAFT_YEAR",
+	"FROM_YEAR",
+	"TO_YEAR",
+	"TEAM_CONFERENCE",
+	"TEAM_DIVISION",
+}
+
+// playerDimension holds the columns of a single player, collected from
+// commonallplayers and commonplayerinfo.
+type playerDimension struct {
+	FromYear  interface{}
+	ToYear    interface{}
+	Position  interface{}
+	Height    interface{}
+	Weight    interface{}
+	DraftYear interface{}
+	// onRoster is set for players on a team roster, their details are
+	// fetched before the first poll.
+	onRoster  bool
+	fetchedAt time.Time
+}
+
+// teamDimension holds the columns of a single team, collected from
+// teaminfocommon.
+type teamDimension struct {
+	Conference interface{}
+	Division   interface{}
+	fetchedAt  time.Time
+}
+
+// dimensionCache keeps the player and team dimension tables in memory. They
+// are refreshed in the background every refreshPeriod, independently of the
+// polls: the list of players is reloaded and the details of rostered and
+// previously referenced players and teams are fetched again, with at most
+// concurrency requests at once. Rows wait for the first refresh, details of
+// players and teams that aren't cached yet are fetched when a row references
+// them.
+type dimensionCache struct {
+	client        *statsClient
+	refreshPeriod time.Duration
+	concurrency   int
+	leagueID      string
+	seasonType    string
+
+	mu      sync.Mutex
+	season  string
+	players map[int]*playerDimension
+	teams   map[int]*teamDimension
+
+	// ready is closed once the first refresh finished.
+	ready     chan struct{}
+	readyOnce sync.Once
+	// trigger requests a refresh before the refresh period elapsed.
+	trigger chan struct{}
+	stop    context.CancelFunc
+	done    chan struct{}
+}
+
+func newDimensionCache(client *statsClient, config EnrichmentConfig, params NBAStatsQueryParams) *dimensionCache {
+	concurrency := config.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &dimensionCache{
+		client:        client,
+		refreshPeriod: config.RefreshPeriod,
+		concurrency:   concurrency,
+		leagueID:      params.LeagueID,
+		season:        params.Season,
+		seasonType:    params.SeasonType,
+		players:       make(map[int]*playerDimension),
+		teams:         make(map[int]*teamDimension),
+		ready:         make(chan struct{}),
+		trigger:       make(chan struct{}, 1),
+	}
+}
+
+// start starts refreshing the dimension tables in the background, until ctx
+// is cancelled or close is called.
+func (c *dimensionCache) start(ctx context.Context) {
+	ctx, c.stop = context.WithCancel(ctx)
+	c.done = make(chan struct{})
+	go c.run(ctx)
+}
+
+func (c *dimensionCache) run(ctx context.Context) {
+	defer close(c.done)
+	ticker := time.NewTicker(c.refreshPeriod)
+	defer ticker.Stop()
+	for {
+		err := c.refresh(ctx)
+		if err != nil && ctx.Err() == nil {
+			// rows are enriched with the details fetched so far, the next
+			// refresh tries again
+			sdk.Logger(ctx).Warn().Err(err).Msg("failed to refresh dimension tables")
+		}
+		c.readyOnce.Do(func() { close(c.ready) })
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-c.trigger:
+		}
+	}
+}
+
+// close stops the background refresh.
+func (c *dimensionCache) close() {
+	if c.stop == nil {
+		return
+	}
+	c.stop()
+	<-c.done
+}
+
+// rollover switches the cache to a new season and triggers a refresh of all
+// dimension tables.
+func (c *dimensionCache) rollover(season string) {
+	c.mu.Lock()
+	c.season = season
+	c.players = make(map[int]*playerDimension)
+	c.teams = make(map[int]*teamDimension)
+	c.mu.Unlock()
+	select {
+	case c.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// wait blocks until the first refresh finished.
+func (c *dimensionCache) wait(ctx context.Context) error {
+	select {
+	case <-c.ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// enrich appends the dimension columns to every result set in data that
+// contains a PLAYER_ID column.
+func (c *dimensionCache) enrich(ctx context.Context, data *ResponseData) error {
+	for i := range data.ResultSets {
+		rs := &data.ResultSets[i]
+		playerCol := rs.column("PLAYER_ID")
+		if playerCol < 0 {
+			continue
+		}
+		teamCol := rs.column("TEAM_ID")
+
+		width := len(rs.Headers)
+		rs.Headers = append(rs.Headers, dimensionColumns...)
+		for j, row := range rs.RowSet {
+			var playerID, teamID interface{}
+			if playerCol < len(row) {
+				playerID = row[playerCol]
+			}
+			if teamCol >= 0 && teamCol < len(row) {
+				teamID = row[teamCol]
+			}
+			values, err := c.values(ctx, playerID, teamID)
+			if err != nil {
+				return err
+			}
+			// pad short rows, so that the dimension columns line up with
+			// their headers
+			for len(row) < width {
+				row = append(row, nil)
+			}
+			rs.RowSet[j] = append(row, values...)
+		}
+	}
+	return nil
+}
+
+// enrichBody joins the dimensions onto the rows of the given encoded
+// response.
+func (c *dimensionCache) enrichBody(ctx context.Context, body []byte) ([]byte, error) {
+	var data ResponseData
+	err := json.Unmarshal(body, &data)
+	if err != nil {
+		return nil, &requestError{kind: errDecode, err: err}
+	}
+	err = c.enrich(ctx, &data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enrich response: %w", err)
+	}
+	return json.Marshal(data)
+}
+
+// values returns the dimension columns of the given player and team, in the
+// order of dimensionColumns. It waits for the first refresh and fetches the
+// details of players and teams that aren't cached yet.
+func (c *dimensionCache) values(ctx context.Context, playerID, teamID interface{}) ([]interface{}, error) {
+	err := c.wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var p playerDimension
+	var t teamDimension
+	if id, ok := toInt(playerID); ok {
+		dim, err := c.player(ctx, id, false)
+		if err != nil {
+			return nil, err
+		}
+		p = dim
+	}
+	if id, ok := toInt(teamID); ok && id != 0 {
+		dim, err := c.team(ctx, id, false)
+		if err != nil {
+			return nil, err
+		}
+		t = dim
+	}
+	return []interface{}{
+		p.Position,
+		p.Height,
+		p.Weight,
+		p.DraftYear,
+		p.FromYear,
+		p.ToYear,
+		t.Conference,
+		t.Division,
+	}, nil
+}
+
+// refresh reloads the list of players and fetches the details of rostered
+// players and of the players and teams referenced before that are older
+// than the refresh period.
+func (c *dimensionCache) refresh(ctx context.Context) error {
+	err := c.refreshPlayers(ctx)
+	if err != nil {
+		return err
+	}
+
+	var playerIDs, teamIDs []int
+	c.mu.Lock()
+	for id, p := range c.players {
+		if (p.onRoster || !p.fetchedAt.IsZero()) && time.Since(p.fetchedAt) >= c.refreshPeriod {
+			playerIDs = append(playerIDs, id)
+		}
+	}
+	for id, t := range c.teams {
+		if time.Since(t.fetchedAt) >= c.refreshPeriod {
+			teamIDs = append(teamIDs, id)
+		}
+	}
+	c.mu.Unlock()
+
+	errs := []error{
+		c.forEach(ctx, playerIDs, func(ctx context.Context, id int) error {
+			_, err := c.player(ctx, id, true)
+			return err
+		}),
+		c.forEach(ctx, teamIDs, func(ctx context.Context, id int) error {
+			_, err := c.team(ctx, id, true)
+			return err
+		}),
+	}
+	sdk.Logger(ctx).Info().
+		Int("players", len(playerIDs)).
+		Int("teams", len(teamIDs)).
+		Msg("refreshed dimension tables")
+	return errors.Join(errs...)
+}
+
+// forEach calls fn for every ID, with at most concurrency calls running at
+// once. It returns the errors of all calls.
+func (c *dimensionCache) forEach(ctx context.Context, ids []int, fn func(ctx context.Context, id int) error) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	sem := make(chan struct{}, c.concurrency)
+	for _, id := range ids {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		}
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := fn(ctx, id); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(id)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// refreshPlayers reloads the list of players from commonallplayers. Details
+// fetched from commonplayerinfo are kept until they are refreshed.
+func (c *dimensionCache) refreshPlayers(ctx context.Context) error {
+	c.mu.Lock()
+	season := c.season
+	c.mu.Unlock()
+
+	values := url.Values{}
+	values.Set("IsOnlyCurrentSeason", "0")
+	values.Set("LeagueID", c.leagueID)
+	values.Set("Season", season)
+
+	data, err := c.client.fetchNBAStatsResponse(ctx, "commonallplayers", values)
+	if err != nil {
+		return fmt.Errorf("failed to refresh players: %w", err)
+	}
+	rs, ok := data.resultSet("CommonAllPlayers")
+	if !ok {
+		return fmt.Errorf("failed to refresh players: %w", schemaError(buildEndpointURL("commonallplayers", values), "missing result set CommonAllPlayers"))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.season != season {
+		// the season rolled over while the players were fetched
+		return nil
+	}
+	for _, row := range rs.rows() {
+		id, ok := toInt(row["PERSON_ID"])
+		if !ok {
+			continue
+		}
+		p, ok := c.players[id]
+		if !ok {
+			p = &playerDimension{}
+			c.players[id] = p
+		}
+		p.FromYear = row["FROM_YEAR"]
+		p.ToYear = row["TO_YEAR"]
+		status, _ := toInt(row["ROSTERSTATUS"])
+		p.onRoster = status == 1
+	}
+	return nil
+}
+
+// player returns the dimension of the given player. Its details are fetched
+// from commonplayerinfo if they were never fetched, or if refresh is set.
+func (c *dimensionCache) player(ctx context.Context, id int, refresh bool) (playerDimension, error) {
+	c.mu.Lock()
+	p, ok := c.players[id]
+	if !ok {
+		p = &playerDimension{}
+		c.players[id] = p
+	}
+	if !refresh && !p.fetchedAt.IsZero() {
+		defer c.mu.Unlock()
+		return *p, nil
+	}
+	c.mu.Unlock()
+
+	values := url.Values{}
+	values.Set("LeagueID", c.leagueID)
+	values.Set("PlayerID", strconv.Itoa(id))
+
+	data, err := c.client.fetchNBAStatsResponse(ctx, "commonplayerinfo", values)
+	if err != nil {
+		return playerDimension{}, fmt.Errorf("failed to fetch info of player %d: %w", id, err)
+	}
+	rs, ok := data.resultSet("CommonPlayerInfo")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !ok || len(rs.RowSet) == 0 {
+		sdk.Logger(ctx).Warn().Int("player_id", id).Msg("no player info found")
+	} else {
+		row := rs.rows()[0]
+		p.Position = row["POSITION"]
+		p.Height = row["HEIGHT"]
+		p.Weight = row["WEIGHT"]
+		p.DraftYear = row["DRAFT_YEAR"]
+	}
+	p.fetchedAt = time.Now()
+	return *p, nil
+}
+
+// team returns the dimension of the given team. It is fetched from
+// teaminfocommon if it was never fetched, or if refresh is set.
+func (c *dimensionCache) team(ctx context.Context, id int, refresh bool) (teamDimension, error) {
+	c.mu.Lock()
+	t, ok := c.teams[id]
+	if !ok {
+		t = &teamDimension{}
+		c.teams[id] = t
+	}
+	if !refresh && !t.fetchedAt.IsZero() {
+		defer c.mu.Unlock()
+		return *t, nil
+	}
+	season := c.season
+	c.mu.Unlock()
+
+	values := url.Values{}
+	values.Set("LeagueID", c.leagueID)
+	values.Set("Season", season)
+	values.Set("SeasonType", c.seasonType)
+	values.Set("TeamID", strconv.Itoa(id))
+
+	data, err := c.client.fetchNBAStatsResponse(ctx, "teaminfocommon", values)
+	if err != nil {
+		return teamDimension{}, fmt.Errorf("failed to fetch info of team %d: %w", id, err)
+	}
+	rs, ok := data.resultSet("TeamInfoCommon")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !ok || len(rs.RowSet) == 0 {
+		sdk.Logger(ctx).Warn().Int("team_id", id).Msg("no team info found")
+	} else {
+		row := rs.rows()[0]
+		t.Conference = row["TEAM_CONFERENCE"]
+		t.Division = row["TEAM_DIVISION"]
+	}
+	t.fetchedAt = time.Now()
+	return *t, nil
+}
+
+// toInt converts a JSON number (decoded as float64) to an int.
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case float64:
+		return int(n), true
+	case int:
+		return n, true
+	case string:
+		i, err := strconv.Atoi(n)
+		return i, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,157 @@
+package nbastats
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+// roundTripperFunc serves requests with a function instead of the network.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// newTestClient returns a stats client whose requests are served by
+// handler.
+func newTestClient(handler http.HandlerFunc) *statsClient {
+	return &statsClient{client: &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			return rec.Result(), nil
+		}),
+	}}
+}
+
+// statsResponse returns the encoded response with a single result set.
+func statsResponse(name string, headers []string, rows ...[]interface{}) []byte {
+	raw, _ := json.Marshal(ResponseData{ResultSets: []ResultSet{{Name: name, Headers: headers, RowSet: rows}}})
+	return raw
+}
+
+// dimensionServer serves the dimension endpoints for the given roster, and
+// counts the requests of every endpoint.
+type dimensionServer struct {
+	roster []int
+	// delay is the time every player info request takes.
+	delay time.Duration
+
+	mu          sync.Mutex
+	requests    map[string]int
+	inFlight    int
+	maxInFlight int
+}
+
+func (s *dimensionServer) serve(w http.ResponseWriter, r *http.Request) {
+	endpoint := strings.TrimPrefix(r.URL.Path, "/stats/")
+	s.mu.Lock()
+	if s.requests == nil {
+		s.requests = make(map[string]int)
+	}
+	s.requests[endpoint]++
+	s.inFlight++
+	if s.inFlight > s.maxInFlight {
+		s.maxInFlight = s.inFlight
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.inFlight--
+		s.mu.Unlock()
+	}()
+
+	switch endpoint {
+	case "commonallplayers":
+		rows := [][]interface{}{{99.0, 0.0, "1990", "2000"}}
+		for _, id := range s.roster {
+			rows = append(rows, []interface{}{float64(id), 1.0, "2015", "2024"})
+		}
+		_, _ = w.Write(statsResponse("CommonAllPlayers", []string{"PERSON_ID", "ROSTERSTATUS", "FROM_YEAR", "TO_YEAR"}, rows...))
+	case "commonplayerinfo":
+		time.Sleep(s.delay)
+		id, _ := strconv.Atoi(r.URL.Query().Get("PlayerID"))
+		_, _ = w.Write(statsResponse("CommonPlayerInfo", []string{"POSITION", "HEIGHT", "WEIGHT", "DRAFT_YEAR"},
+			[]interface{}{"G", "6-2", "185", strconv.Itoa(2000 + id)}))
+	case "teaminfocommon":
+		_, _ = w.Write(statsResponse("TeamInfoCommon", []string{"TEAM_CONFERENCE", "TEAM_DIVISION"},
+			[]interface{}{"West", "Pacific"}))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *dimensionServer) count(endpoint string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[endpoint]
+}
+
+func TestDimensionCache_Enrich(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	srv := &dimensionServer{roster: []int{1}}
+	cache := newDimensionCache(newTestClient(srv.serve), EnrichmentConfig{RefreshPeriod: time.Hour, Concurrency: 2}, NBAStatsQueryParams{})
+	cache.start(ctx)
+	defer cache.close()
+
+	data := ResponseData{ResultSets: []ResultSet{{
+		Headers: []string{"PLAYER_ID", "DIST_MILES", "TEAM_ID"},
+		RowSet: [][]interface{}{
+			{1.0, 2.5, 1610612744.0},
+			{99.0, 2.1}, // shorter than the headers
+		},
+	}}}
+	is.NoErr(cache.enrich(ctx, &data))
+
+	rs := data.ResultSets[0]
+	is.Equal(len(rs.Headers), 3+len(dimensionColumns))
+	is.Equal(rs.RowSet[0], []interface{}{1.0, 2.5, 1610612744.0, "G", "6-2", "185", "2001", "2015", "2024", "West", "Pacific"})
+	is.Equal(rs.RowSet[1], []interface{}{99.0, 2.1, nil, "G", "6-2", "185", "2099", "1990", "2000", nil, nil})
+	// the rostered player was fetched by the refresh, the other one when a
+	// row referenced it
+	is.Equal(srv.count("commonallplayers"), 1)
+	is.Equal(srv.count("commonplayerinfo"), 2)
+	is.Equal(srv.count("teaminfocommon"), 1)
+
+	// cached details aren't fetched again
+	is.NoErr(cache.enrich(ctx, &ResponseData{ResultSets: []ResultSet{{
+		Headers: []string{"PLAYER_ID"},
+		RowSet:  [][]interface{}{{1.0}},
+	}}}))
+	is.Equal(srv.count("commonplayerinfo"), 2)
+}
+
+func TestDimensionCache_RefreshConcurrency(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	srv := &dimensionServer{delay: 5 * time.Millisecond}
+	for id := 1; id <= 20; id++ {
+		srv.roster = append(srv.roster, id)
+	}
+	cache := newDimensionCache(newTestClient(srv.serve), EnrichmentConfig{RefreshPeriod: time.Hour, Concurrency: 3}, NBAStatsQueryParams{})
+
+	is.NoErr(cache.refresh(ctx))
+	is.Equal(srv.count("commonplayerinfo"), 20)
+	is.True(srv.maxInFlight <= 3)
+	is.True(srv.maxInFlight > 1)
+
+	// details are only fetched again once they are older than the refresh
+	// period
+	is.NoErr(cache.refresh(ctx))
+	is.Equal(srv.count("commonallplayers"), 2)
+	is.Equal(srv.count("commonplayerinfo"), 20)
+
+	cache.refreshPeriod = 0
+	is.NoErr(cache.refresh(ctx))
+	is.Equal(srv.count("commonplayerinfo"), 40)
+}
@@ -0,0 +1,94 @@
+package nbastats
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// acceptEncoding lists the content encodings the client can decode. Setting
+// the Accept-Encoding header disables the transparent gzip decoding of
+// http.Transport, decodeBody takes care of all encodings instead.
+const acceptEncoding = "gzip, deflate, br"
+
+// decodeBody returns a reader of the decoded response body. Encodings listed
+// in the Content-Encoding header are removed in the reverse order in which
+// they were applied.
+func decodeBody(resp *http.Response) (io.ReadCloser, error) {
+	var reader io.ReadCloser = resp.Body
+	encodings := strings.Split(resp.Header.Get("Content-Encoding"), ",")
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var err error
+		reader, err = decodeReader(reader, strings.TrimSpace(strings.ToLower(encodings[i])))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return reader, nil
+}
+
+// decodeReader wraps r in a reader removing a single content encoding.
+// Closing the returned reader also closes r.
+func decodeReader(r io.ReadCloser, encoding string) (io.ReadCloser, error) {
+	switch encoding {
+	case "", "identity":
+		return r, nil
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode gzip body: %w", err)
+		}
+		return readCloser{Reader: gz, closers: []io.Closer{gz, r}}, nil
+	case "deflate":
+		// "deflate" is supposed to be zlib wrapped, but some servers send a
+		// raw deflate stream, which is detected by the missing zlib header
+		br := bufio.NewReader(r)
+		header, _ := br.Peek(2)
+		if isZlibHeader(header) {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode deflate body: %w", err)
+			}
+			return readCloser{Reader: zr, closers: []io.Closer{zr, r}}, nil
+		}
+		fr := flate.NewReader(br)
+		return readCloser{Reader: fr, closers: []io.Closer{fr, r}}, nil
+	case "br":
+		return readCloser{Reader: brotli.NewReader(r), closers: []io.Closer{r}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+}
+
+// isZlibHeader checks if b starts with a zlib header (RFC 1950) using the
+// deflate compression method.
+func isZlibHeader(b []byte) bool {
+	if len(b) < 2 {
+		return false
+	}
+	return b[0]&0x0f == 8 && (uint16(b[0])<<8|uint16(b[1]))%31 == 0
+}
+
+// readCloser reads from a decoding reader and closes it together with the
+// underlying readers.
+type readCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r readCloser) Close() error {
+	var firstErr error
+	for _, c := range r.closers {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
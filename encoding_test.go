@@ -0,0 +1,94 @@
+package nbastats
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/matryer/is"
+)
+
+const encodingTestBody = `{"resultSets":[{"name":"A","headers":["PLAYER_ID"],"rowSet":[[1],[2],[3]]}]}`
+
+// encode returns body compressed with newWriter.
+func encode(t *testing.T, body []byte, newWriter func(io.Writer) io.WriteCloser) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := newWriter(&buf)
+	if _, err := w.Write(body); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestStatsClient_ContentEncoding(t *testing.T) {
+	gzipWriter := func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }
+	zlibWriter := func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }
+	flateWriter := func(w io.Writer) io.WriteCloser {
+		fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+		return fw
+	}
+	brotliWriter := func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) }
+
+	body := []byte(encodingTestBody)
+	testCases := []struct {
+		name     string
+		encoding string
+		body     []byte
+		wantErr  bool
+	}{
+		{"identity", "", body, false},
+		{"gzip", "gzip", encode(t, body, gzipWriter), false},
+		{"deflate", "deflate", encode(t, body, zlibWriter), false},
+		{"raw deflate", "deflate", encode(t, body, flateWriter), false},
+		{"brotli", "br", encode(t, body, brotliWriter), false},
+		{"gzip then brotli", "gzip, br", encode(t, encode(t, body, gzipWriter), brotliWriter), false},
+		{"unknown", "compress", body, true},
+		{"invalid gzip", "gzip", body, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			ctx := context.Background()
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				is.Equal(r.Header.Get("Accept-Encoding"), acceptEncoding)
+				if tc.encoding != "" {
+					w.Header().Set("Content-Encoding", tc.encoding)
+				}
+				w.Write(tc.body)
+			}))
+			defer srv.Close()
+			c := newServerClient(t, srv, HTTPConfig{})
+
+			url := buildNBAStatsURL(NewNBAStatsQueryParams())
+			body, err := c.openNBAStats(ctx, url)
+			if tc.wantErr {
+				is.True(errors.Is(err, errDecode))
+				is.True(!isRetryable(err))
+				return
+			}
+			is.NoErr(err)
+			st := newRowStream(url, body, nil, windowSeason, "PerGame", "ts", 0)
+			defer st.close()
+			for i := 1; i <= 3; i++ {
+				rec, err := st.next(ctx)
+				is.NoErr(err)
+				is.Equal(rec.Key, sdk.StructuredData{"PLAYER_ID": i})
+			}
+			_, err = st.next(ctx)
+			is.Equal(err, io.EOF)
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package nbastats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Kinds of errors returned by the stats client. Every requestError wraps
+// exactly one of them (or errResponseTooLarge or errCircuitOpen), so they
+// can be checked with errors.Is.
+var (
+	// errRateLimited is returned when stats.nba.com throttles requests.
+	errRateLimited = errors.New("rate limited")
+	// errUpstream is returned when stats.nba.com fails with a 5xx status
+	// code or the connection to it fails.
+	errUpstream = errors.New("upstream error")
+	// errBadRequest is returned when stats.nba.com rejects the request,
+	// usually because of invalid query parameters.
+	errBadRequest = errors.New("bad request")
+	// errDecode is returned when a response is not valid JSON.
+	errDecode = errors.New("decode failure")
+	// errSchemaMismatch is returned when a response doesn't contain the
+	// expected result sets or columns.
+	errSchemaMismatch = errors.New("schema mismatch")
+	// errTimeout is returned when a request times out.
+	errTimeout = errors.New("timeout")
+)
+
+// requestError describes a failed request to stats.nba.com.
+type requestError struct {
+	// kind is one of the error kinds above.
+	kind error
+	// URL of the failed request.
+	URL string
+	// StatusCode of the response, 0 if no response was received.
+	StatusCode int
+	err        error
+}
+
+func (e *requestError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(e.kind.Error())
+	if e.URL != "" {
+		fmt.Fprintf(&sb, " (url: %s", e.URL)
+		if e.StatusCode != 0 {
+			fmt.Fprintf(&sb, ", status code: %d", e.StatusCode)
+		}
+		sb.WriteString(")")
+	}
+	if e.err != nil {
+		sb.WriteString(": ")
+		sb.WriteString(e.err.Error())
+	}
+	return sb.String()
+}
+
+func (e *requestError) Unwrap() []error {
+	if e.err == nil {
+		return []error{e.kind}
+	}
+	return []error{e.kind, e.err}
+}
+
+// isRetryable returns true if err is caused by a condition that is expected
+// to go away on its own, so the request should be retried after a backoff.
+// Bad requests, decode failures and schema mismatches need a change in
+// configuration or code and are not retried.
+func isRetryable(err error) bool {
+	return errors.Is(err, errRateLimited) ||
+		errors.Is(err, errUpstream) ||
+		errors.Is(err, errTimeout) ||
+		errors.Is(err, errCircuitOpen)
+}
+
+// statusError returns the error describing a response with a non-200
+// status code.
+func statusError(url string, resp *http.Response) error {
+	kind := errUpstream
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		kind = errRateLimited
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusGatewayTimeout:
+		kind = errTimeout
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		kind = errBadRequest
+	}
+	return &requestError{
+		kind:       kind,
+		URL:        url,
+		StatusCode: resp.StatusCode,
+		err:        fmt.Errorf("unexpected status %q", resp.Status),
+	}
+}
+
+// transportError returns the error describing a request that failed without
+// a response. Errors caused by the cancellation of ctx are returned as is, so
+// that a stopping pipeline isn't reported as a failure.
+func transportError(ctx context.Context, url string, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	kind := errUpstream
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		kind = errTimeout
+	case errors.Is(err, errNotRecorded):
+		// retrying won't add the request to the cassette
+		kind = errBadRequest
+	}
+	return &requestError{kind: kind, URL: url, err: err}
+}
+
+// decodeError returns the error describing a response body that couldn't
+// be read or decoded. Errors that were already classified and errors caused
+// by the cancellation of ctx are returned as is.
+func decodeError(ctx context.Context, url string, err error) error {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if errors.Is(err, errResponseTooLarge) {
+		return &requestError{kind: errResponseTooLarge, URL: url}
+	}
+
+	kind := errUpstream
+	var netErr net.Error
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		kind = errTimeout
+	case errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || errors.Is(err, io.ErrUnexpectedEOF):
+		kind = errDecode
+	}
+	return &requestError{kind: kind, URL: url, err: err}
+}
+
+// schemaError returns the error describing a response that doesn't have the
+// expected shape.
+func schemaError(url string, format string, args ...interface{}) error {
+	return &requestError{kind: errSchemaMismatch, URL: url, err: fmt.Errorf(format, args...)}
+}
@@ -0,0 +1,83 @@
+package nbastats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"syscall"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+// timeoutError is a net.Error that timed out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsRetryable(t *testing.T) {
+	ctx := context.Background()
+	const url = "https://stats.nba.com/stats/test"
+	status := func(code int) error {
+		return statusError(url, &http.Response{StatusCode: code, Status: fmt.Sprintf("%d %s", code, http.StatusText(code))})
+	}
+
+	testCases := []struct {
+		name      string
+		err       error
+		kind      error
+		retryable bool
+	}{
+		{"400", status(http.StatusBadRequest), errBadRequest, false},
+		{"403", status(http.StatusForbidden), errBadRequest, false},
+		{"404", status(http.StatusNotFound), errBadRequest, false},
+		{"408", status(http.StatusRequestTimeout), errTimeout, true},
+		{"429", status(http.StatusTooManyRequests), errRateLimited, true},
+		{"500", status(http.StatusInternalServerError), errUpstream, true},
+		{"502", status(http.StatusBadGateway), errUpstream, true},
+		{"503", status(http.StatusServiceUnavailable), errUpstream, true},
+		{"504", status(http.StatusGatewayTimeout), errTimeout, true},
+		{"connection refused", transportError(ctx, url, syscall.ECONNREFUSED), errUpstream, true},
+		{"transport timeout", transportError(ctx, url, timeoutError{}), errTimeout, true},
+		{"transport deadline", transportError(ctx, url, context.DeadlineExceeded), errTimeout, true},
+		{"not recorded", transportError(ctx, url, errNotRecorded), errBadRequest, false},
+		{"read timeout", decodeError(ctx, url, timeoutError{}), errTimeout, true},
+		{"read reset", decodeError(ctx, url, syscall.ECONNRESET), errUpstream, true},
+		{"syntax error", decodeError(ctx, url, json.Unmarshal([]byte("{"), &struct{}{})), errDecode, false},
+		{"type error", decodeError(ctx, url, json.Unmarshal([]byte(`"a"`), new(int))), errDecode, false},
+		{"truncated", decodeError(ctx, url, io.ErrUnexpectedEOF), errDecode, false},
+		{"too large", decodeError(ctx, url, errResponseTooLarge), errResponseTooLarge, false},
+		{"schema mismatch", schemaError(url, "missing result set %q", "A"), errSchemaMismatch, false},
+		{"circuit open", &requestError{kind: errCircuitOpen, URL: url}, errCircuitOpen, true},
+		{"wrapped", fmt.Errorf("failed to read window: %w", status(http.StatusServiceUnavailable)), errUpstream, true},
+		{
+			"cache write failure",
+			fmt.Errorf("failed to cache response of %s: %w", url, &fs.PathError{Op: "write", Path: "cache", Err: syscall.ENOSPC}),
+			nil,
+			false,
+		},
+		{"cancelled", transportError(cancelledContext(), url, context.Canceled), nil, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(isRetryable(tc.err), tc.retryable)
+			if tc.kind != nil {
+				is.True(errors.Is(tc.err, tc.kind))
+			}
+		})
+	}
+}
+
+// cancelledContext returns a context that is already cancelled.
+func cancelledContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
@@ -0,0 +1,114 @@
+package nbastats
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// headerProfiles contains the named sets of headers sent with every request
+// to stats.nba.com. Which headers stats.nba.com requires changes from time
+// to time, the profiles make it possible to switch between known working
+// sets without a release.
+var headerProfiles = map[string]map[string]string{
+	// stats mimics the stats.nba.com website, this is the set of headers the
+	// connector has always sent.
+	"stats": {
+		"User-Agent":         "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:72.0) Gecko/20100101 Firefox/72.0",
+		"Accept":             "application/json, text/plain, */*",
+		"Accept-Language":    "en-US,en;q=0.5",
+		"x-nba-stats-origin": "stats",
+		"x-nba-stats-token":  "true",
+		"Connection":         "keep-alive",
+		"Referer":            "https://stats.nba.com/",
+		"Pragma":             "no-cache",
+		"Cache-Control":      "no-cache",
+	},
+	// browser mimics a current browser visiting www.nba.com, which queries
+	// stats.nba.com cross-origin.
+	"browser": {
+		"User-Agent":      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+		"Accept":          "*/*",
+		"Accept-Language": "en-US,en;q=0.9",
+		"Connection":      "keep-alive",
+		"Origin":          "https://www.nba.com",
+		"Referer":         "https://www.nba.com/",
+		"Sec-Fetch-Dest":  "empty",
+		"Sec-Fetch-Mode":  "cors",
+		"Sec-Fetch-Site":  "same-site",
+	},
+	// minimal only sends the headers without which stats.nba.com never
+	// responds.
+	"minimal": {
+		"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:72.0) Gecko/20100101 Firefox/72.0",
+		"Accept":     "application/json, text/plain, */*",
+		"Referer":    "https://stats.nba.com/",
+	},
+}
+
+// defaultHeaderProfile is used if no header profile is configured.
+const defaultHeaderProfile = "stats"
+
+// DefaultHeaders returns the headers of the default header profile, for
+// tools requesting stats.nba.com outside of the connector.
+func DefaultHeaders() http.Header {
+	headers, _ := HTTPConfig{}.requestHeaders()
+	return headers
+}
+
+// requestHeaders builds the headers sent with every request from the
+// configured profile and the custom headers, which take precedence.
+func (c HTTPConfig) requestHeaders() (http.Header, error) {
+	profileName := c.HeaderProfile
+	if profileName == "" {
+		profileName = defaultHeaderProfile
+	}
+	profile, ok := headerProfiles[profileName]
+	if !ok {
+		return nil, fmt.Errorf("unknown header profile %q", profileName)
+	}
+	headers := make(http.Header, len(profile))
+	for name, value := range profile {
+		headers.Set(name, value)
+	}
+
+	for _, line := range splitLines(c.Headers) {
+		name, value, ok := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid header %q, expected \"Name: value\"", line)
+		}
+		headers.Set(name, strings.TrimSpace(value))
+	}
+	return headers, nil
+}
+
+// userAgentRotation returns the user agents to rotate through, or nil if
+// the user agent of the header profile should be used.
+func (c HTTPConfig) userAgentRotation() []string {
+	return splitLines(c.UserAgents)
+}
+
+// splitLines returns the non-empty, trimmed lines of s.
+func splitLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// userAgentRotator hands out user agents round-robin.
+type userAgentRotator struct {
+	userAgents []string
+	next       uint32
+}
+
+func (r *userAgentRotator) userAgent() string {
+	n := atomic.AddUint32(&r.next, 1) - 1
+	return r.userAgents[int(n)%len(r.userAgents)]
+}
@@ -0,0 +1,558 @@
+package nbastats
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/klauspost/compress/zstd"
+)
+
+// JSONLConfig configures the JSON Lines files written by the destination.
+type JSONLConfig struct {
+	// Content of every line: "payload" writes the payload of the record,
+	// "envelope" the whole record in the format configured with
+	// sdk.record.format (the OpenCDC envelope by default).
+	Content string `json:"content" validate:"inclusion=payload|envelope" default:"payload"`
+	// MaxFileSize is the approximate size in bytes after which a file is
+	// completed and a new one is started.
+	MaxFileSize int64 `json:"maxFileSize" default:"104857600"`
+	// RotateInterval is the time after which a file is completed and a new
+	// one is started. 0 disables time based rotation.
+	RotateInterval time.Duration `json:"rotateInterval" default:"1h"`
+	// Compression of the files: "none", "gzip" or "zstd".
+	Compression string `json:"compression" validate:"inclusion=none|gzip|zstd" default:"none"`
+}
+
+// jsonlRotateCheck is the maximum period in which files are checked for
+// time based rotation.
+const jsonlRotateCheck = time.Second
+
+// jsonlWriter appends records to JSON Lines files, one series of files per
+// name rendered from the name template (see seriesName).
+type jsonlWriter struct {
+	dir    string
+	config JSONLConfig
+	names  nameTemplate
+
+	m     sync.Mutex
+	files map[string]*jsonlFile
+	seq   int
+	// rotateErr is the error of a failed time based rotation, it is returned
+	// by the next write.
+	rotateErr error
+
+	// stop and done are nil if files aren't rotated by time.
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newJSONLWriter(dir string, config JSONLConfig, names nameTemplate) (*jsonlWriter, error) {
+	err := validateJSONLCompression(config.Compression)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output dir: %w", err)
+	}
+	err = recoverJSONLFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	w := &jsonlWriter{dir: dir, config: config, names: names, files: make(map[string]*jsonlFile)}
+	if config.RotateInterval > 0 {
+		w.stop = make(chan struct{})
+		w.done = make(chan struct{})
+		go w.run()
+	}
+	return w, nil
+}
+
+// run completes files that are due for rotation, also when no records are
+// written to them, until the writer is closed.
+func (w *jsonlWriter) run() {
+	defer close(w.done)
+	period := w.config.RotateInterval / 10
+	if period > jsonlRotateCheck {
+		period = jsonlRotateCheck
+	}
+	if period < time.Millisecond {
+		period = time.Millisecond
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.rotate()
+		}
+	}
+}
+
+// rotate completes all files older than the rotate interval.
+func (w *jsonlWriter) rotate() {
+	w.m.Lock()
+	defer w.m.Unlock()
+	for series, f := range w.files {
+		if !f.expired(w.config) {
+			continue
+		}
+		delete(w.files, series)
+		err := f.complete()
+		if err != nil && w.rotateErr == nil {
+			w.rotateErr = err
+		}
+	}
+}
+
+// write appends the records to their files. The lines are synced to disk
+// before write returns, so that acknowledged records survive a crash, and
+// the returned count only includes records that were synced.
+func (w *jsonlWriter) write(ctx context.Context, records []sdk.Record) (int, error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if err := w.rotateErr; err != nil {
+		w.rotateErr = nil
+		return 0, fmt.Errorf("failed to rotate file: %w", err)
+	}
+
+	// first holds the index of the first record written to every file
+	first := make(map[*jsonlFile]int)
+	n, err := w.writeRecords(ctx, records, first)
+	synced, syncErr := w.sync(first, n)
+	if syncErr != nil && synced < n {
+		return synced, errors.Join(err, syncErr)
+	}
+	return n, err
+}
+
+// writeRecords writes the lines of the records to the buffers of their
+// files and returns the number of records written.
+func (w *jsonlWriter) writeRecords(ctx context.Context, records []sdk.Record, first map[*jsonlFile]int) (int, error) {
+	for i, rec := range records {
+		line, err := jsonlLine(w.config.Content, rec)
+		if err != nil {
+			return i, fmt.Errorf("invalid record %d: %w", i, err)
+		}
+		if line == nil {
+			sdk.Logger(ctx).Debug().Str("operation", rec.Operation.String()).Msg("skipping record without payload")
+			continue
+		}
+
+		f, err := w.file(ctx, rec, first)
+		if err != nil {
+			return i, err
+		}
+		err = f.write(line)
+		if err != nil {
+			return i, fmt.Errorf("failed to write record %d to %q: %w", i, f.path, err)
+		}
+		if _, ok := first[f]; !ok {
+			first[f] = i
+		}
+	}
+	return len(records), nil
+}
+
+// sync syncs the files the first n records were written to, in the order of
+// their first record. If a file fails, it returns the number of records
+// that were synced: all records before the first record of the file. The
+// failed file is dropped and left in progress, it is recovered on the next
+// start.
+func (w *jsonlWriter) sync(first map[*jsonlFile]int, n int) (int, error) {
+	files := make([]*jsonlFile, 0, len(first))
+	for f := range first {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return first[files[i]] < first[files[j]] })
+
+	for _, f := range files {
+		err := f.flush()
+		if err != nil {
+			w.drop(f)
+			return first[f], fmt.Errorf("failed to write %q: %w", f.path, err)
+		}
+	}
+	return n, nil
+}
+
+// drop removes a file that can't be written anymore from the open files.
+func (w *jsonlWriter) drop(f *jsonlFile) {
+	for series, open := range w.files {
+		if open == f {
+			delete(w.files, series)
+		}
+	}
+	_ = f.file.Close()
+}
+
+// jsonlLine returns the line written for the record, or nil if the record
+// is skipped.
+func jsonlLine(content string, rec sdk.Record) ([]byte, error) {
+	if content == "envelope" {
+		return rec.Bytes(), nil
+	}
+	switch data := rec.Payload.After.(type) {
+	case nil:
+		return nil, nil
+	case sdk.RawData:
+		if len(data) == 0 {
+			return nil, nil
+		}
+		if !json.Valid(data) {
+			return nil, errors.New("raw payload is not valid JSON")
+		}
+		return data, nil
+	default:
+		return json.Marshal(data)
+	}
+}
+
+// file returns the open file of the record's series, completing it first if
+// it is due for rotation. A completed file is removed from first, unless
+// completing it fails, its records are reported as not written by sync then.
+func (w *jsonlWriter) file(ctx context.Context, rec sdk.Record, first map[*jsonlFile]int) (*jsonlFile, error) {
+	series, err := w.seriesName(rec)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := w.files[series]
+	if ok && !f.due(w.config) {
+		return f, nil
+	}
+	if ok {
+		delete(w.files, series)
+		err := f.complete()
+		if err != nil {
+			return nil, err
+		}
+		delete(first, f)
+		sdk.Logger(ctx).Debug().Str("file", f.path).Msg("completed jsonl file")
+	}
+
+	w.seq++
+	name, err := w.names.fileName(rec, time.Now(), w.seq)
+	if err != nil {
+		return nil, err
+	}
+	f, err = createJSONLFile(filepath.Join(w.dir, name+jsonlExtension(w.config.Compression)), w.config.Compression)
+	if err != nil {
+		return nil, err
+	}
+	w.files[series] = f
+	return f, nil
+}
+
+// seriesName returns the name of the series of files the record is written
+// to: the name rendered with the timestamp and sequence number of the first
+// file, so that records are split the way the name template splits them.
+func (w *jsonlWriter) seriesName(rec sdk.Record) (string, error) {
+	return w.names.fileName(rec, time.Time{}, 0)
+}
+
+func (w *jsonlWriter) close() error {
+	if w.stop != nil {
+		close(w.stop)
+		<-w.done
+	}
+
+	w.m.Lock()
+	defer w.m.Unlock()
+	errs := []error{w.rotateErr}
+	for series, f := range w.files {
+		errs = append(errs, f.complete())
+		delete(w.files, series)
+	}
+	return errors.Join(errs...)
+}
+
+func validateJSONLCompression(compression string) error {
+	switch compression {
+	case "", "none", "gzip", "zstd":
+		return nil
+	default:
+		return fmt.Errorf("unsupported compression %q", compression)
+	}
+}
+
+// jsonlExtension returns the extension of JSON Lines files with the given
+// compression.
+func jsonlExtension(compression string) string {
+	switch compression {
+	case "gzip":
+		return ".jsonl.gz"
+	case "zstd":
+		return ".jsonl.zst"
+	default:
+		return ".jsonl"
+	}
+}
+
+// jsonlSnapshot is the JSON Lines file of a snapshot.
+type jsonlSnapshot struct {
+	content string
+	file    *jsonlFile
+}
+
+func newJSONLSnapshotWriter(dir string, config JSONLConfig, names nameTemplate) (*snapshotWriter, error) {
+	err := validateJSONLCompression(config.Compression)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output dir: %w", err)
+	}
+	return newSnapshotWriter(names, func(_ sdk.Record, name string) (snapshotFile, error) {
+		f, err := createJSONLFile(filepath.Join(dir, name+jsonlExtension(config.Compression)), config.Compression)
+		if err != nil {
+			return nil, err
+		}
+		return &jsonlSnapshot{content: config.Content, file: f}, nil
+	}), nil
+}
+
+func (s *jsonlSnapshot) write(rec sdk.Record) error {
+	line, err := jsonlLine(s.content, rec)
+	if err != nil || line == nil {
+		return err
+	}
+	return s.file.write(line)
+}
+
+func (s *jsonlSnapshot) complete() error {
+	return s.file.complete()
+}
+
+func (s *jsonlSnapshot) discard() error {
+	return s.file.discard()
+}
+
+// jsonlFile is a JSON Lines file being written. Like Parquet files, it is
+// written next to its final path and renamed once complete. Unlike Parquet
+// files, the lines written so far are readable before the file is complete,
+// so files left in progress by a crash are recovered (see
+// recoverJSONLFiles).
+type jsonlFile struct {
+	path      string
+	createdAt time.Time
+	file      *os.File
+	counter   *countingWriter
+	// compressor is nil for uncompressed files.
+	compressor io.WriteCloser
+	buf        *bufio.Writer
+}
+
+func createJSONLFile(path, compression string) (*jsonlFile, error) {
+	file, err := os.Create(inProgressPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jsonl file: %w", err)
+	}
+	return newJSONLFile(file, path, compression)
+}
+
+// newJSONLFile returns the JSON Lines file written to file, which is moved
+// to path once complete.
+func newJSONLFile(file *os.File, path, compression string) (*jsonlFile, error) {
+	f := &jsonlFile{path: path, createdAt: time.Now(), file: file, counter: &countingWriter{w: file}}
+
+	var w io.Writer = f.counter
+	var err error
+	switch compression {
+	case "gzip":
+		f.compressor = gzip.NewWriter(f.counter)
+	case "zstd":
+		f.compressor, err = zstd.NewWriter(f.counter)
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+			return nil, fmt.Errorf("failed to create zstd writer: %w", err)
+		}
+	}
+	if f.compressor != nil {
+		w = f.compressor
+	}
+	f.buf = bufio.NewWriter(w)
+	return f, nil
+}
+
+func (f *jsonlFile) write(line []byte) error {
+	_, err := f.buf.Write(line)
+	if err == nil {
+		err = f.buf.WriteByte('\n')
+	}
+	return err
+}
+
+// flush writes the buffered lines to the file and syncs it to disk, so that
+// they can be recovered if the file is never completed. Compressed files end
+// the current compressed block, which is readable without the rest of the
+// file.
+func (f *jsonlFile) flush() error {
+	err := f.buf.Flush()
+	if err != nil {
+		return err
+	}
+	if c, ok := f.compressor.(interface{ Flush() error }); ok {
+		err = c.Flush()
+		if err != nil {
+			return err
+		}
+	}
+	return f.file.Sync()
+}
+
+// due returns true if the file reached the configured size or age.
+func (f *jsonlFile) due(config JSONLConfig) bool {
+	return f.expired(config) || (config.MaxFileSize > 0 && f.counter.n+int64(f.buf.Buffered()) >= config.MaxFileSize)
+}
+
+// expired returns true if the file is older than the rotate interval.
+func (f *jsonlFile) expired(config JSONLConfig) bool {
+	return config.RotateInterval > 0 && time.Since(f.createdAt) >= config.RotateInterval
+}
+
+// discard removes the incomplete file.
+func (f *jsonlFile) discard() error {
+	if f.compressor != nil {
+		// releases the resources of the compressor, the output is discarded
+		_ = f.compressor.Close()
+	}
+	return discardFile(f.file)
+}
+
+// complete flushes all buffered lines and moves the file to its final path.
+func (f *jsonlFile) complete() error {
+	err := f.buf.Flush()
+	if err == nil && f.compressor != nil {
+		err = f.compressor.Close()
+	}
+	if err == nil {
+		err = f.file.Sync()
+	}
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to complete %q: %w", f.path, err)
+	}
+	err = os.Rename(f.file.Name(), f.path)
+	if err != nil {
+		return fmt.Errorf("failed to complete %q: %w", f.path, err)
+	}
+	return nil
+}
+
+// recoverJSONLFiles completes the JSON Lines files in dir that were left in
+// progress, e.g. by a crash. Their records were already acknowledged, so
+// every complete line is kept and a trailing partial line is dropped.
+func recoverJSONLFiles(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, ".*.inprogress"))
+	if err != nil {
+		return fmt.Errorf("failed to list jsonl files in progress: %w", err)
+	}
+	for _, p := range paths {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(p), "."), ".inprogress")
+		compression, ok := jsonlCompression(name)
+		if !ok {
+			continue
+		}
+		err := recoverJSONLFile(p, filepath.Join(dir, name), compression)
+		if err != nil {
+			return fmt.Errorf("failed to recover %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// recoverJSONLFile writes the complete lines of the file in progress at src
+// to a new file at path and removes src.
+func recoverJSONLFile(src, path, compression string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	// the recovered file is written to a temporary file, since src already
+	// is at the path files are written to until they are complete
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".recover-*")
+	if err != nil {
+		return err
+	}
+	f, err := newJSONLFile(tmp, path, compression)
+	if err != nil {
+		return err
+	}
+
+	lines := 0
+	r, err := jsonlReader(in, compression)
+	if err == nil {
+		defer r.Close()
+		br := bufio.NewReader(r)
+		for {
+			line, err := br.ReadBytes('\n')
+			if err != nil {
+				// the end of a file in progress is never a complete line
+				break
+			}
+			err = f.write(line[:len(line)-1])
+			if err != nil {
+				_ = f.discard()
+				return err
+			}
+			lines++
+		}
+	}
+	if lines == 0 {
+		err = f.discard()
+	} else {
+		err = f.complete()
+	}
+	if err != nil {
+		return err
+	}
+	in.Close()
+	return os.Remove(src)
+}
+
+// jsonlCompression returns the compression of the JSON Lines file with the
+// given name, ok is false if it isn't a JSON Lines file.
+func jsonlCompression(name string) (compression string, ok bool) {
+	for _, c := range []string{"gzip", "zstd", "none"} {
+		if strings.HasSuffix(name, jsonlExtension(c)) {
+			return c, true
+		}
+	}
+	return "", false
+}
+
+// jsonlReader returns a reader of the decompressed lines in r.
+func jsonlReader(r io.Reader, compression string) (io.ReadCloser, error) {
+	switch compression {
+	case "gzip":
+		return gzip.NewReader(r)
+	case "zstd":
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	default:
+		return io.NopCloser(r), nil
+	}
+}
@@ -0,0 +1,352 @@
+package nbastats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// LeaderboardConfig configures the leaderboards served with format
+// leaderboard.
+type LeaderboardConfig struct {
+	// Address is the address the HTTP API listens on.
+	Address string `json:"address" default:"localhost:8090"`
+	// Columns are the columns leaderboards are maintained for. Rows are
+	// sorted in descending order, append ":asc" to a column to sort it in
+	// ascending order.
+	Columns []string `json:"columns"`
+	// Limit is the number of rows returned by leaderboard queries without a
+	// limit.
+	Limit int `json:"limit" default:"10"`
+}
+
+// leaderboardColumns parses the configured columns.
+func (c LeaderboardConfig) leaderboardColumns() ([]leaderboardColumn, error) {
+	if len(c.Columns) == 0 {
+		return nil, errors.New("format leaderboard requires at least one leaderboard column")
+	}
+	columns := make([]leaderboardColumn, len(c.Columns))
+	for i, spec := range c.Columns {
+		name, order, _ := strings.Cut(strings.TrimSpace(spec), ":")
+		switch order {
+		case "", "desc":
+		case "asc":
+			columns[i].ascending = true
+		default:
+			return nil, fmt.Errorf("invalid leaderboard column %q: unsupported order %q", spec, order)
+		}
+		if name == "" {
+			return nil, fmt.Errorf("invalid leaderboard column %q", spec)
+		}
+		columns[i].name = name
+	}
+	return columns, nil
+}
+
+// leaderboardColumn is a column leaderboards are maintained for.
+type leaderboardColumn struct {
+	name      string
+	ascending bool
+}
+
+// leaderboardStore keeps the latest rows of every board, identified by the
+// record key, and the keys of the rows sorted by every leaderboard column.
+// It is written by the destination and read by the HTTP API concurrently.
+type leaderboardStore struct {
+	columns []leaderboardColumn
+
+	mu     sync.RWMutex
+	boards map[string]*board
+}
+
+// board is the latest rows of one board.
+type board struct {
+	rows map[string]sdk.StructuredData
+	// sorted are the keys of the rows sorted by each leaderboard column.
+	// Rows without a numeric value in the column are left out.
+	sorted map[string][]string
+}
+
+// leaderboardChange upserts the row with the given key, or deletes it if
+// fields is nil.
+type leaderboardChange struct {
+	board  string
+	key    string
+	fields sdk.StructuredData
+}
+
+func newLeaderboardStore(columns []leaderboardColumn) *leaderboardStore {
+	return &leaderboardStore{columns: columns, boards: make(map[string]*board)}
+}
+
+// apply applies the changes and sorts the changed boards.
+func (s *leaderboardStore) apply(changes []leaderboardChange) {
+	if len(changes) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := make(map[*board]bool)
+	for _, c := range changes {
+		b, ok := s.boards[c.board]
+		if !ok {
+			b = &board{rows: make(map[string]sdk.StructuredData)}
+			s.boards[c.board] = b
+		}
+		if c.fields == nil {
+			delete(b.rows, c.key)
+		} else {
+			b.rows[c.key] = c.fields
+		}
+		changed[b] = true
+	}
+	for b := range changed {
+		s.sort(b)
+	}
+}
+
+// replace replaces all rows of the board.
+func (s *leaderboardStore) replace(name string, rows map[string]sdk.StructuredData) {
+	b := &board{rows: rows}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sort(b)
+	s.boards[name] = b
+}
+
+// sort sorts the rows of the board by every leaderboard column. Ties are
+// sorted by key, so that the order is stable across restarts.
+func (s *leaderboardStore) sort(b *board) {
+	b.sorted = make(map[string][]string, len(s.columns))
+	for _, col := range s.columns {
+		keys := make([]string, 0, len(b.rows))
+		values := make(map[string]float64, len(b.rows))
+		for key, row := range b.rows {
+			if v, ok := numericValue(row[col.name]); ok {
+				keys = append(keys, key)
+				values[key] = v
+			}
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			vi, vj := values[keys[i]], values[keys[j]]
+			if vi == vj {
+				return keys[i] < keys[j]
+			}
+			if col.ascending {
+				return vi < vj
+			}
+			return vi > vj
+		})
+		b.sorted[col.name] = keys
+	}
+}
+
+// leaderboardFile is the format of the file the store is persisted to.
+type leaderboardFile struct {
+	Boards map[string][]leaderboardFileRow `json:"boards"`
+}
+
+type leaderboardFileRow struct {
+	Key    string             `json:"key"`
+	Fields sdk.StructuredData `json:"fields"`
+}
+
+// save writes the rows of all boards to the file at path. The file is
+// replaced atomically.
+func (s *leaderboardStore) save(path string) error {
+	s.mu.RLock()
+	file := leaderboardFile{Boards: make(map[string][]leaderboardFileRow, len(s.boards))}
+	for name, b := range s.boards {
+		rows := make([]leaderboardFileRow, 0, len(b.rows))
+		for key, fields := range b.rows {
+			rows = append(rows, leaderboardFileRow{Key: key, Fields: fields})
+		}
+		file.Boards[name] = rows
+	}
+	raw, err := json.Marshal(file)
+	s.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := inProgressPath(path)
+	err = os.WriteFile(tmp, raw, 0o644)
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	return err
+}
+
+// load reads the rows saved to the file at path, if it exists.
+func (s *leaderboardStore) load(path string) error {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var file leaderboardFile
+	err = json.Unmarshal(raw, &file)
+	if err != nil {
+		return err
+	}
+	for name, saved := range file.Boards {
+		rows := make(map[string]sdk.StructuredData, len(saved))
+		for _, row := range saved {
+			rows[row.Key] = row.Fields
+		}
+		s.replace(name, rows)
+	}
+	return nil
+}
+
+// leaderboardWriter keeps the latest rows in a leaderboard store, which is
+// served over HTTP and, if a path is configured, persisted to a file after
+// every batch. In snapshot mode, a board is replaced once all rows of a
+// snapshot were written.
+type leaderboardWriter struct {
+	store  *leaderboardStore
+	names  nameTemplate
+	path   string
+	server *http.Server
+	// snapshots is nil if the writer isn't in snapshot mode.
+	snapshots *snapshotWriter
+}
+
+func newLeaderboardWriter(path string, config LeaderboardConfig, names nameTemplate, snapshot bool) (*leaderboardWriter, error) {
+	columns, err := config.leaderboardColumns()
+	if err != nil {
+		return nil, err
+	}
+	w := &leaderboardWriter{store: newLeaderboardStore(columns), names: names, path: path}
+	if path != "" {
+		err = os.MkdirAll(filepath.Dir(path), 0o755)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create leaderboard dir: %w", err)
+		}
+		err = w.store.load(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load leaderboards from %q: %w", path, err)
+		}
+	}
+	if snapshot {
+		w.snapshots = newSnapshotWriter(names, func(_ sdk.Record, name string) (snapshotFile, error) {
+			return &boardSnapshot{store: w.store, name: name, rows: make(map[string]sdk.StructuredData)}, nil
+		})
+	}
+
+	ln, err := net.Listen("tcp", config.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %q: %w", config.Address, err)
+	}
+	w.server = &http.Server{
+		Handler:           newLeaderboardAPI(w.store, config.Limit),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		_ = w.server.Serve(ln)
+	}()
+	return w, nil
+}
+
+func (w *leaderboardWriter) write(ctx context.Context, records []sdk.Record) (int, error) {
+	var n int
+	var err error
+	if w.snapshots != nil {
+		n, err = w.snapshots.write(ctx, records)
+	} else {
+		n, err = w.upsert(records)
+	}
+	if n > 0 && w.path != "" {
+		if saveErr := w.store.save(w.path); saveErr != nil {
+			return 0, errors.Join(err, fmt.Errorf("failed to save leaderboards: %w", saveErr))
+		}
+	}
+	return n, err
+}
+
+// upsert upserts the rows of the records and deletes the rows of delete
+// records.
+func (w *leaderboardWriter) upsert(records []sdk.Record) (int, error) {
+	changes := make([]leaderboardChange, 0, len(records))
+	// the changes of the valid records are applied even if a record fails
+	defer func() { w.store.apply(changes) }()
+	for i, rec := range records {
+		name, err := w.names.fileName(rec, time.Now(), 0)
+		if err != nil {
+			return i, err
+		}
+		key, err := recordKey(rec)
+		if err != nil {
+			return i, fmt.Errorf("invalid record %d: %w", i, err)
+		}
+		if rec.Operation == sdk.OperationDelete {
+			changes = append(changes, leaderboardChange{board: name, key: key})
+			continue
+		}
+		fields, err := recordFields(rec)
+		if err != nil {
+			return i, fmt.Errorf("invalid record %d: %w", i, err)
+		}
+		if fields != nil {
+			changes = append(changes, leaderboardChange{board: name, key: key, fields: fields})
+		}
+	}
+	return len(records), nil
+}
+
+func (w *leaderboardWriter) close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := w.server.Shutdown(ctx)
+	if w.snapshots != nil {
+		err = errors.Join(err, w.snapshots.close())
+	}
+	return err
+}
+
+// boardSnapshot collects the rows of a snapshot in memory and replaces the
+// rows of the board once the snapshot is complete.
+type boardSnapshot struct {
+	store *leaderboardStore
+	name  string
+	rows  map[string]sdk.StructuredData
+}
+
+func (s *boardSnapshot) write(rec sdk.Record) error {
+	if rec.Operation == sdk.OperationDelete {
+		return nil
+	}
+	key, err := recordKey(rec)
+	if err != nil {
+		return err
+	}
+	fields, err := recordFields(rec)
+	if err != nil || fields == nil {
+		return err
+	}
+	s.rows[key] = fields
+	return nil
+}
+
+func (s *boardSnapshot) complete() error {
+	s.store.replace(s.name, s.rows)
+	return nil
+}
+
+func (s *boardSnapshot) discard() error {
+	return nil
+}
@@ -0,0 +1,193 @@
+package nbastats
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// leaderboardAPI serves the boards of a leaderboard store as JSON:
+//
+//	GET /boards                          boards with their row counts and columns
+//	GET /boards/{board}/{column}         top rows of a leaderboard, ?limit=n&team=t
+//	GET /players/{id}                    rows and ranks of a player in all boards
+type leaderboardAPI struct {
+	store *leaderboardStore
+	limit int
+}
+
+func newLeaderboardAPI(store *leaderboardStore, limit int) http.Handler {
+	api := &leaderboardAPI{store: store, limit: limit}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/boards", api.serveBoards)
+	mux.HandleFunc("/boards/", api.serveLeaderboard)
+	mux.HandleFunc("/players/", api.servePlayer)
+	return mux
+}
+
+// boardInfo describes a board in the response of /boards.
+type boardInfo struct {
+	Name    string   `json:"name"`
+	Rows    int      `json:"rows"`
+	Columns []string `json:"columns"`
+}
+
+// rankedRow is a row of a leaderboard. Rank is the position of the row in
+// the whole leaderboard, also if the rows are filtered by team.
+type rankedRow struct {
+	Rank int                `json:"rank"`
+	Row  sdk.StructuredData `json:"row"`
+}
+
+type leaderboardResponse struct {
+	Board  string      `json:"board"`
+	Column string      `json:"column"`
+	Rows   []rankedRow `json:"rows"`
+}
+
+// playerRow is the row of a player in a board and its rank in every
+// leaderboard of the board.
+type playerRow struct {
+	Board string             `json:"board"`
+	Row   sdk.StructuredData `json:"row"`
+	Ranks map[string]int     `json:"ranks"`
+}
+
+type playerResponse struct {
+	PlayerID int         `json:"playerId"`
+	Boards   []playerRow `json:"boards"`
+}
+
+func (api *leaderboardAPI) serveBoards(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	api.store.mu.RLock()
+	boards := make([]boardInfo, 0, len(api.store.boards))
+	for name, b := range api.store.boards {
+		info := boardInfo{Name: name, Rows: len(b.rows)}
+		for _, col := range api.store.columns {
+			info.Columns = append(info.Columns, col.name)
+		}
+		boards = append(boards, info)
+	}
+	api.store.mu.RUnlock()
+
+	sort.Slice(boards, func(i, j int) bool { return boards[i].Name < boards[j].Name })
+	writeJSON(w, boards)
+}
+
+func (api *leaderboardAPI) serveLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	name, column, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/boards/"), "/")
+	if !ok || name == "" || column == "" || strings.Contains(column, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	limit := api.limit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, fmt.Sprintf("invalid limit %q", v), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	team := r.URL.Query().Get("team")
+
+	api.store.mu.RLock()
+	defer api.store.mu.RUnlock()
+	b, ok := api.store.boards[name]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown board %q", name), http.StatusNotFound)
+		return
+	}
+	keys, ok := b.sorted[column]
+	if !ok {
+		http.Error(w, fmt.Sprintf("no leaderboard for column %q", column), http.StatusNotFound)
+		return
+	}
+
+	resp := leaderboardResponse{Board: name, Column: column, Rows: []rankedRow{}}
+	for i, key := range keys {
+		if len(resp.Rows) == limit {
+			break
+		}
+		row := b.rows[key]
+		if team != "" && !onTeam(row, team) {
+			continue
+		}
+		resp.Rows = append(resp.Rows, rankedRow{Rank: i + 1, Row: row})
+	}
+	writeJSON(w, resp)
+}
+
+func (api *leaderboardAPI) servePlayer(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/players/"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	resp := playerResponse{PlayerID: id, Boards: []playerRow{}}
+	api.store.mu.RLock()
+	for name, b := range api.store.boards {
+		for key, row := range b.rows {
+			if rowID, ok := toInt(row["PLAYER_ID"]); !ok || rowID != id {
+				continue
+			}
+			pr := playerRow{Board: name, Row: row, Ranks: make(map[string]int)}
+			for column, keys := range b.sorted {
+				for i, k := range keys {
+					if k == key {
+						pr.Ranks[column] = i + 1
+						break
+					}
+				}
+			}
+			resp.Boards = append(resp.Boards, pr)
+		}
+	}
+	api.store.mu.RUnlock()
+
+	if len(resp.Boards) == 0 {
+		http.Error(w, fmt.Sprintf("unknown player %d", id), http.StatusNotFound)
+		return
+	}
+	sort.Slice(resp.Boards, func(i, j int) bool { return resp.Boards[i].Board < resp.Boards[j].Board })
+	writeJSON(w, resp)
+}
+
+// onTeam returns true if the row belongs to the team, given as abbreviation
+// or team ID.
+func onTeam(row sdk.StructuredData, team string) bool {
+	if abbr, ok := row["TEAM_ABBREVIATION"].(string); ok && strings.EqualFold(abbr, team) {
+		return true
+	}
+	id, ok := toInt(row["TEAM_ID"])
+	return ok && strconv.Itoa(id) == team
+}
+
+func allowGet(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return true
+	}
+	w.Header().Set("Allow", "GET, HEAD")
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
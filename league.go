@@ -0,0 +1,139 @@
+package nbastats
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// league describes a league served by stats.nba.com.
+type league struct {
+	// Name is the user facing name of the league.
+	Name string
+	// ID is the value of the LeagueID query parameter.
+	ID string
+	// SingleYearSeasons is true if seasons are identified by a single year
+	// (e.g. "2023") rather than a year range (e.g. "2023-24").
+	SingleYearSeasons bool
+	// SeasonStart is the month and day on which a new season becomes the
+	// current season, its year is ignored.
+	SeasonStart time.Time
+	// Endpoints contains the endpoints that return data for this league.
+	Endpoints map[string]bool
+}
+
+var (
+	multiYearSeasonFormat  = regexp.MustCompile(`^\d{4}-\d{2}$`)
+	singleYearSeasonFormat = regexp.MustCompile(`^\d{4}$`)
+)
+
+// leagues contains all supported leagues keyed by the value of the league
+// parameter.
+var leagues = map[string]league{
+	"nba": {
+		Name: "NBA",
+		ID:   "00",
+		// preseason games start in early October
+		SeasonStart: time.Date(0, time.October, 1, 0, 0, 0, 0, time.UTC),
+		Endpoints: endpointSet(
+			"leaguedashptstats",
+			"commonteamroster",
+			"commonteamyears",
+			"commonallplayers",
+			"commonplayerinfo",
+			"teaminfocommon",
+		),
+	},
+	"wnba": {
+		Name:              "WNBA",
+		ID:                "10",
+		SingleYearSeasons: true,
+		// the season runs from May to October within a single year
+		SeasonStart: time.Date(0, time.May, 1, 0, 0, 0, 0, time.UTC),
+		Endpoints: endpointSet(
+			"leaguedashptstats",
+			"commonteamroster",
+			"commonteamyears",
+			"commonallplayers",
+			"commonplayerinfo",
+			"teaminfocommon",
+		),
+	},
+	"gleague": {
+		Name: "G League",
+		ID:   "20",
+		// the regular season tips off in November
+		SeasonStart: time.Date(0, time.November, 1, 0, 0, 0, 0, time.UTC),
+		// player tracking data is not collected in the G League
+		Endpoints: endpointSet(
+			"commonteamroster",
+			"commonteamyears",
+			"commonallplayers",
+			"commonplayerinfo",
+			"teaminfocommon",
+		),
+	},
+}
+
+func endpointSet(endpoints ...string) map[string]bool {
+	set := make(map[string]bool, len(endpoints))
+	for _, e := range endpoints {
+		set[e] = true
+	}
+	return set
+}
+
+// currentSeason returns the season that is active at the given time. Before
+// the league's season start date the previous season is still considered
+// current.
+func (l league) currentSeason(now time.Time) string {
+	year := now.Year()
+	start := time.Date(year, l.SeasonStart.Month(), l.SeasonStart.Day(), 0, 0, 0, 0, now.Location())
+	if now.Before(start) {
+		year--
+	}
+	if l.SingleYearSeasons {
+		return strconv.Itoa(year)
+	}
+	return fmt.Sprintf("%d-%02d", year, (year+1)%100)
+}
+
+// validateSeason checks that season is formatted the way the league
+// identifies its seasons.
+func (l league) validateSeason(season string) error {
+	if l.SingleYearSeasons {
+		if !singleYearSeasonFormat.MatchString(season) {
+			return fmt.Errorf("%s seasons must be formatted as YYYY, got %q", l.Name, season)
+		}
+		return nil
+	}
+	if !multiYearSeasonFormat.MatchString(season) {
+		return fmt.Errorf("%s seasons must be formatted as YYYY-YY, got %q", l.Name, season)
+	}
+	return nil
+}
+
+// validateEndpoints returns an error listing the endpoints that don't
+// return data for this league.
+func (l league) validateEndpoints(endpoints []string) error {
+	var unsupported []string
+	for _, e := range endpoints {
+		if !l.Endpoints[e] {
+			unsupported = append(unsupported, e)
+		}
+	}
+	if len(unsupported) > 0 {
+		sort.Strings(unsupported)
+		return fmt.Errorf("endpoints not available for the %s: %s", l.Name, strings.Join(unsupported, ", "))
+	}
+	return nil
+}
+
+// seasonStartYear returns the year in which the given season starts.
+func seasonStartYear(season string) int {
+	year, _ := strconv.Atoi(season[:4])
+	return year
+}
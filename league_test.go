@@ -0,0 +1,41 @@
+package nbastats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestLeague_CurrentSeason(t *testing.T) {
+	testCases := []struct {
+		league string
+		now    time.Time
+		want   string
+	}{
+		{"nba", time.Date(2024, time.January, 15, 12, 0, 0, 0, time.UTC), "2023-24"},
+		{"nba", time.Date(2024, time.September, 30, 23, 59, 59, 0, time.UTC), "2023-24"},
+		{"nba", time.Date(2024, time.October, 1, 0, 0, 0, 0, time.UTC), "2024-25"},
+		{"nba", time.Date(2024, time.December, 31, 23, 59, 59, 0, time.UTC), "2024-25"},
+		{"nba", time.Date(2099, time.October, 1, 0, 0, 0, 0, time.UTC), "2099-00"},
+		{"wnba", time.Date(2024, time.January, 15, 12, 0, 0, 0, time.UTC), "2023"},
+		{"wnba", time.Date(2024, time.April, 30, 23, 59, 59, 0, time.UTC), "2023"},
+		{"wnba", time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC), "2024"},
+		{"wnba", time.Date(2024, time.October, 20, 0, 0, 0, 0, time.UTC), "2024"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.league+"/"+tc.now.Format(time.RFC3339), func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(leagues[tc.league].currentSeason(tc.now), tc.want)
+		})
+	}
+}
+
+func TestLeague_CurrentSeason_Location(t *testing.T) {
+	is := is.New(t)
+	// the rollover happens at midnight in the location of the given time
+	loc := time.FixedZone("UTC-5", -5*60*60)
+	now := time.Date(2024, time.September, 30, 23, 0, 0, 0, loc) // October 1 in UTC
+	is.Equal(leagues["nba"].currentSeason(now), "2023-24")
+	is.Equal(leagues["nba"].currentSeason(now.UTC()), "2024-25")
+}
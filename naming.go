@@ -0,0 +1,198 @@
+package nbastats
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// defaultNameTemplates are the name templates used by the formats if no
+// name template is configured.
+var defaultNameTemplates = map[string]string{
+	"csv":         "{{.Collection}}{{with .Season}}_{{.}}{{end}}",
+	"parquet":     "part-{{.Timestamp}}-{{.Seq}}",
+	"sqlite":      "{{.Collection}}",
+	"jsonl":       "{{.Collection}}-{{.Timestamp}}-{{.Seq}}",
+	"leaderboard": "{{.Collection}}",
+}
+
+// snapshotNameTemplate is the default name template of the files and tables
+// snapshots are written to, except for Parquet. Every season, measure type
+// and per mode of a collection has its own snapshot.
+const snapshotNameTemplate = "{{.Collection}}{{with .Season}}_{{.}}{{end}}{{with .MeasureType}}_{{.}}{{end}}{{with .PerMode}}_{{.}}{{end}}"
+
+// defaultSnapshotNameTemplates are the name templates used by the formats
+// in snapshot mode if no name template is configured. Parquet files already
+// are in a partition per season and measure type.
+var defaultSnapshotNameTemplates = map[string]string{
+	"csv":     snapshotNameTemplate,
+	"parquet": "snapshot{{with .PerMode}}_{{.}}{{end}}",
+	"sqlite":  snapshotNameTemplate,
+	"jsonl":   snapshotNameTemplate,
+	// the boards of a leaderboard destination are kept by a single pipeline
+	"leaderboard": "{{.Collection}}",
+	// reports are rendered with all snapshots, one report per day
+	"report": "report-{{.Date}}",
+}
+
+// nameData is the data name templates are executed with.
+type nameData struct {
+	// Collection is the collection of the record, "records" for records that
+	// don't belong to one.
+	Collection string
+	// League, Season, SeasonType, MeasureType, PerMode and Date are taken
+	// from the record metadata set by the source, they are empty if it is
+	// missing.
+	League      string
+	Season      string
+	SeasonType  string
+	MeasureType string
+	PerMode     string
+	Date        string
+	// Timestamp is the UTC time the file was started, formatted as
+	// 20060102T150405Z.
+	Timestamp string
+	// Seq is the sequence number of the file, formatted with 5 digits.
+	Seq string
+}
+
+// nameTemplate names the files or tables records are written to.
+type nameTemplate struct {
+	tmpl *template.Template
+}
+
+// parseNameTemplate parses the name template, falling back to the given
+// default template if text is empty.
+func parseNameTemplate(text, fallback string) (nameTemplate, error) {
+	if text == "" {
+		text = fallback
+	}
+	tmpl, err := template.New("name").Parse(text)
+	if err != nil {
+		return nameTemplate{}, fmt.Errorf("invalid name template: %w", err)
+	}
+	// names depend on the record, execute the template once to catch
+	// references to unknown fields before the first record arrives
+	err = tmpl.Execute(new(strings.Builder), nameData{})
+	if err != nil {
+		return nameTemplate{}, fmt.Errorf("invalid name template: %w", err)
+	}
+	return nameTemplate{tmpl: tmpl}, nil
+}
+
+// name returns the name of the file or table the record is written to. The
+// file started at started with sequence number seq.
+func (t nameTemplate) name(rec sdk.Record, started time.Time, seq int) (string, error) {
+	name, err := t.execute(nameData{
+		Collection:  recordCollection(rec),
+		League:      rec.Metadata[metadataLeague],
+		Season:      rec.Metadata[metadataSeason],
+		SeasonType:  rec.Metadata[metadataSeasonType],
+		MeasureType: rec.Metadata[metadataMeasureType],
+		PerMode:     rec.Metadata[metadataPerMode],
+		Date:        rec.Metadata[metadataDate],
+		Timestamp:   started.UTC().Format("20060102T150405Z"),
+		Seq:         fmt.Sprintf("%05d", seq),
+	})
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		return "", errors.New("name template returned an empty name")
+	}
+	return name, nil
+}
+
+// seriesFileName returns the file name the record's file name is grouped
+// by over time: the name rendered without the date, timestamp and sequence
+// number. Once a record of a series is written to a new file, the previous
+// file of the series won't be written to anymore.
+func (t nameTemplate) seriesFileName(rec sdk.Record) (string, error) {
+	name, err := t.execute(nameData{
+		Collection:  recordCollection(rec),
+		League:      rec.Metadata[metadataLeague],
+		Season:      rec.Metadata[metadataSeason],
+		SeasonType:  rec.Metadata[metadataSeasonType],
+		MeasureType: rec.Metadata[metadataMeasureType],
+		PerMode:     rec.Metadata[metadataPerMode],
+	})
+	if err != nil {
+		return "", err
+	}
+	return fileName(name), nil
+}
+
+// uses returns true if the template references the field.
+func (t nameTemplate) uses(field string) bool {
+	found := false
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Nodes {
+				walk(c)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Cmds {
+				walk(c)
+			}
+		case *parse.CommandNode:
+			for _, a := range n.Args {
+				walk(a)
+			}
+		case *parse.ChainNode:
+			walk(n.Node)
+			found = found || (len(n.Field) > 0 && n.Field[0] == field)
+		case *parse.FieldNode:
+			found = found || n.Ident[0] == field
+		case *parse.VariableNode:
+			found = found || (n.Ident[0] == "$" && len(n.Ident) > 1 && n.Ident[1] == field)
+		}
+	}
+	walk(t.tmpl.Tree.Root)
+	return found
+}
+
+func (t nameTemplate) execute(data nameData) (string, error) {
+	var sb strings.Builder
+	err := t.tmpl.Execute(&sb, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute name template: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// fileName returns the name of the file the record is written to, without
+// extension. Characters that aren't safe in file names are replaced.
+func (t nameTemplate) fileName(rec sdk.Record, started time.Time, seq int) (string, error) {
+	name, err := t.name(rec, started, seq)
+	if err != nil {
+		return "", err
+	}
+	return fileName(name), nil
+}
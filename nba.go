@@ -1,11 +1,6 @@
 package nbastats
 
 import (
-	"compress/gzip"
-	"fmt"
-	"io"
-	"io/ioutil"
-	"net/http"
 	"net/url"
 	"strconv"
 	"time"
@@ -35,7 +30,7 @@ type NBAStatsQueryParams struct {
 	PlayerOrTeam     string // Default is "Player"
 	PlayerPosition   string // Default is empty
 	PtMeasureType    string // Default is "SpeedDistance"
-	Season           string // Default is "2023-24"
+	Season           string // Default is the current NBA season
 	SeasonSegment    string // Default is empty
 	SeasonType       string // Default is "Regular Season"
 	StarterBench     string // Default is empty
@@ -56,14 +51,16 @@ func NewNBAStatsQueryParams() NBAStatsQueryParams {
 		PerMode:        "PerGame",
 		PlayerOrTeam:   "Player",
 		PtMeasureType:  "SpeedDistance",
-		Season:         "2023-24",
+		Season:         leagues["nba"].currentSeason(time.Now()),
 		SeasonType:     "Regular Season",
 		TeamID:         0,
 	}
 }
 
+// statsBaseURL is the prefix shared by every stats.nba.com endpoint.
+const statsBaseURL = "https://stats.nba.com/stats/"
+
 func buildNBAStatsURL(params NBAStatsQueryParams) string {
-	baseURL := "https://stats.nba.com/stats/leaguedashptstats"
 	values := url.Values{}
 
 	values.Set("College", params.College)
@@ -98,86 +95,66 @@ func buildNBAStatsURL(params NBAStatsQueryParams) string {
 	values.Set("VsDivision", params.VsDivision)
 	values.Set("Weight", params.Weight)
 
-	return baseURL + "?" + values.Encode()
+	return buildEndpointURL("leaguedashptstats", values)
+}
+
+// URL returns the leaguedashptstats URL queried with the parameters.
+func (params NBAStatsQueryParams) URL() string {
+	return buildNBAStatsURL(params)
+}
+
+// buildEndpointURL returns the URL of a stats.nba.com endpoint queried with
+// the given values.
+func buildEndpointURL(endpoint string, values url.Values) string {
+	return statsBaseURL + endpoint + "?" + values.Encode()
 }
 
 // ResponseData structure reflects the JSON structure of the API response.
 type ResponseData struct {
 	Resource   string      `json:"resource"`
 	Parameters interface{} `json:"parameters"`
-	ResultSets []struct {
-		Name    string          `json:"name"`
-		Headers []string        `json:"headers"`
-		RowSet  [][]interface{} `json:"rowSet"`
-	} `json:"resultSets"`
+	ResultSets []ResultSet `json:"resultSets"`
 }
 
-func fetchNBASpeedDistanceStats(perMode string) ([]byte, error) {
-	// url := "https://stats.nba.com/stats/leaguedashptstats?College=&Conference=&Country=&DateFrom=&DateTo=&Division=&DraftPick=&DraftYear=&GameScope=&Height=&ISTRound=&LastNGames=0&LeagueID=00&Location=&Month=0&OpponentTeamID=0&Outcome=&PORound=0&PerMode=PerGame&PlayerExperience=&PlayerOrTeam=Player&PlayerPosition=&PtMeasureType=SpeedDistance&Season=2023-24&SeasonSegment=&SeasonType=Regular%20Season&StarterBench=&TeamID=0&VsConference=&VsDivision=&Weight="
-
-	var nbaStatsQuery = NewNBAStatsQueryParams()
-	nbaStatsQuery.PerMode = perMode
-	url := buildNBAStatsURL(nbaStatsQuery)
-	fmt.Printf("url: %s\n", url)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		fmt.Println(err)
-		return nil, err
-	}
-
-	// Set the required headers
-	req.Header.Set("Host", "stats.nba.com")
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:72.0) Gecko/20100101 Firefox/72.0")
-	req.Header.Set("Accept", "application/json, text/plain, */*")
-	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
-	// Note: The "Accept-Encoding" header is managed by the http.Client. If you set it manually, you must also handle the encoding yourself.
-	// req.Header.Set("Accept-Encoding", "gzip, deflate, br")
-	req.Header.Set("x-nba-stats-origin", "stats")
-	req.Header.Set("x-nba-stats-token", "true")
-	req.Header.Set("Connection", "keep-alive")
-	req.Header.Set("Referer", "https://stats.nba.com/")
-	req.Header.Set("Pragma", "no-cache")
-	req.Header.Set("Cache-Control", "no-cache")
-
-	// Create a new client with a timeout
-	client := &http.Client{
-		Timeout: time.Second * 30, // Timeout after 30 seconds
-	}
-
-	// Make the request
-	resp, err := client.Do(req)
-	if err != nil {
-		fmt.Println(err)
-		return nil, err
-	}
-	defer resp.Body.Close()
+// ResultSet is a single named table inside a ResponseData.
+type ResultSet struct {
+	Name    string          `json:"name"`
+	Headers []string        `json:"headers"`
+	RowSet  [][]interface{} `json:"rowSet"`
+}
 
-	// Check for status code 200 OK
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("error: status code %d\n", resp.StatusCode)
-		return nil, err
+// resultSet returns the result set with the given name.
+func (r ResponseData) resultSet(name string) (ResultSet, bool) {
+	for _, rs := range r.ResultSets {
+		if rs.Name == name {
+			return rs, true
+		}
 	}
+	return ResultSet{}, false
+}
 
-	// Handle gzip encoding
-	var reader io.ReadCloser
-	switch resp.Header.Get("Content-Encoding") {
-	case "gzip":
-		reader, err = gzip.NewReader(resp.Body)
-		if err != nil {
-			fmt.Println(err)
-			return nil, err
+// column returns the index of the given header, or -1 if the result set
+// doesn't contain it.
+func (rs ResultSet) column(header string) int {
+	for i, h := range rs.Headers {
+		if h == header {
+			return i
 		}
-		defer reader.Close()
-	default:
-		reader = resp.Body
 	}
+	return -1
+}
 
-	// Read and print response body
-	body, err := ioutil.ReadAll(reader)
-	if err != nil {
-		fmt.Println(err)
-		return nil, err
+// rows returns the rows of the result set keyed by their header.
+func (rs ResultSet) rows() []map[string]interface{} {
+	rows := make([]map[string]interface{}, 0, len(rs.RowSet))
+	for _, row := range rs.RowSet {
+		m := make(map[string]interface{}, len(rs.Headers))
+		for i, h := range rs.Headers {
+			if i < len(row) {
+				m[h] = row[i]
+			}
+		}
+		rows = append(rows, m)
 	}
-
-	return body, err
+	return rows
 }
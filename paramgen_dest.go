@@ -9,20 +9,162 @@ import (
 
 func (DestinationConfig) Parameters() map[string]sdk.Parameter {
 	return map[string]sdk.Parameter{
-		"destinationConfigParam": {
-			Default:     "yes",
-			Description: "destinationConfigParam must be either yes or no (defaults to yes).",
+		"format": {
+			Default:     "csv",
+			Description: "format of the written files: \"csv\" writes the rows of one collection and season to one CSV file, \"parquet\" writes typed Parquet files in Hive style partitions, \"sqlite\" upserts the rows into a SQLite database with one table per collection, \"jsonl\" appends every record to rotated JSON Lines files, \"leaderboard\" keeps the latest rows in memory and serves sorted leaderboards over HTTP, \"prometheus\" exposes the numeric fields of the latest rows as Prometheus gauges, \"report\" renders the latest snapshots through a template into report files, \"webhook\" posts the records as JSON to a URL.",
 			Type:        sdk.ParameterTypeString,
 			Validations: []sdk.Validation{
-				sdk.ValidationInclusion{List: []string{"yes", "no"}},
+				sdk.ValidationInclusion{List: []string{"csv", "parquet", "sqlite", "jsonl", "leaderboard", "prometheus", "report", "webhook"}},
 			},
 		},
-		"global_config_param_name": {
+		"jsonl.compression": {
+			Default:     "none",
+			Description: "compression of the files: \"none\", \"gzip\" or \"zstd\".",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{
+				sdk.ValidationInclusion{List: []string{"none", "gzip", "zstd"}},
+			},
+		},
+		"jsonl.content": {
+			Default:     "payload",
+			Description: "content of every line: \"payload\" writes the payload of the record, \"envelope\" the whole record in the format configured with sdk.record.format (the OpenCDC envelope by default).",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{
+				sdk.ValidationInclusion{List: []string{"payload", "envelope"}},
+			},
+		},
+		"jsonl.maxFileSize": {
+			Default:     "104857600",
+			Description: "maxFileSize is the approximate size in bytes after which a file is completed and a new one is started.",
+			Type:        sdk.ParameterTypeInt,
+			Validations: []sdk.Validation{},
+		},
+		"jsonl.rotateInterval": {
+			Default:     "1h",
+			Description: "rotateInterval is the time after which a file is completed and a new one is started. 0 disables time based rotation.",
+			Type:        sdk.ParameterTypeDuration,
+			Validations: []sdk.Validation{},
+		},
+		"leaderboard.address": {
+			Default:     "localhost:8090",
+			Description: "address is the address the HTTP API listens on.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{},
+		},
+		"leaderboard.columns": {
+			Default:     "",
+			Description: "columns are the columns leaderboards are maintained for. Rows are sorted in descending order, append \":asc\" to a column to sort it in ascending order.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{},
+		},
+		"leaderboard.limit": {
+			Default:     "10",
+			Description: "limit is the number of rows returned by leaderboard queries without a limit.",
+			Type:        sdk.ParameterTypeInt,
+			Validations: []sdk.Validation{},
+		},
+		"nameTemplate": {
+			Default:     "",
+			Description: "nameTemplate is a Go template of the names of the written files, without extension, or of the tables with format sqlite. Empty selects the default of the format and write mode.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{},
+		},
+		"parquet.maxFileSize": {
+			Default:     "134217728",
+			Description: "maxFileSize is the approximate size in bytes after which a file is completed and a new one is started.",
+			Type:        sdk.ParameterTypeInt,
+			Validations: []sdk.Validation{},
+		},
+		"parquet.maxRecords": {
+			Default:     "1000000",
+			Description: "maxRecords is the number of records after which a file is completed and a new one is started. Files are completed after every batch regardless.",
+			Type:        sdk.ParameterTypeInt,
+			Validations: []sdk.Validation{},
+		},
+		"path": {
+			Default:     "",
+			Description: "path is the directory the files are written to, or the database file with format sqlite. With format leaderboard, it is the optional file the leaderboards are persisted to, formats prometheus and webhook ignore it.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{},
+		},
+		"prometheus.address": {
+			Default:     "localhost:9464",
+			Description: "address is the address the metrics endpoint listens on.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{},
+		},
+		"prometheus.columns": {
+			Default:     "",
+			Description: "columns are the numeric columns exposed as gauges. Empty exposes all numeric columns except IDs.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{},
+		},
+		"prometheus.path": {
+			Default:     "/metrics",
+			Description: "path is the path of the metrics endpoint.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{},
+		},
+		"prometheus.prefix": {
+			Default:     "nba_",
+			Description: "prefix is prepended to the metric names, which are the lower case column names.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{},
+		},
+		"report.template": {
+			Default:     "",
+			Description: "template is the Go template file reports are rendered with. The extension of the reports is the extension of the template without a trailing \".tmpl\", templates of HTML reports are executed with html/template.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{},
+		},
+		"webhook.maxRecords": {
+			Default:     "100",
+			Description: "maxRecords is the maximum number of records posted in one request, larger batches are split into several requests.",
+			Type:        sdk.ParameterTypeInt,
+			Validations: []sdk.Validation{},
+		},
+		"webhook.maxRetries": {
+			Default:     "5",
+			Description: "maxRetries is the number of times a failed request is retried before the write fails.",
+			Type:        sdk.ParameterTypeInt,
+			Validations: []sdk.Validation{},
+		},
+		"webhook.maxRetryBackoff": {
+			Default:     "1m",
+			Description: "maxRetryBackoff is the maximum delay between retries.",
+			Type:        sdk.ParameterTypeDuration,
+			Validations: []sdk.Validation{},
+		},
+		"webhook.retryBackoff": {
+			Default:     "1s",
+			Description: "retryBackoff is the delay before the first retry, it is doubled with every further retry.",
+			Type:        sdk.ParameterTypeDuration,
+			Validations: []sdk.Validation{},
+		},
+		"webhook.secret": {
+			Default:     "",
+			Description: "secret is the key requests are signed with, empty disables signing.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{},
+		},
+		"webhook.timeout": {
+			Default:     "10s",
+			Description: "timeout is the maximum time a single request may take.",
+			Type:        sdk.ParameterTypeDuration,
+			Validations: []sdk.Validation{},
+		},
+		"webhook.url": {
+			Default:     "",
+			Description: "url is the url the records are posted to.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{},
+		},
+		"writeMode": {
 			Default:     "",
-			Description: "global_config_param_name is named global_config_param_name and needs to be provided by the user.",
+			Description: "writeMode selects how records are written: \"append\" appends them to the output, \"upsert\" updates the row with the key of the record, \"snapshot\" atomically replaces the previous snapshot of a leaderboard once all of its rows were written. The formats support the modes listed in the README, empty selects the first mode supported by the format.",
 			Type:        sdk.ParameterTypeString,
 			Validations: []sdk.Validation{
-				sdk.ValidationRequired{},
+				sdk.ValidationInclusion{List: []string{"append", "upsert", "snapshot"}},
 			},
 		},
 	}
@@ -7,8 +7,206 @@ import (
 	sdk "github.com/conduitio/conduit-connector-sdk"
 )
 
-func (Config) Parameters() map[string]sdk.Parameter {
+func (SourceConfig) Parameters() map[string]sdk.Parameter {
 	return map[string]sdk.Parameter{
+		"archive.order": {
+			Default:     "name",
+			Description: "order in which the files are processed: \"name\" orders them by file name, \"date\" by the date embedded in the file name (e.g. 2024-01-31 or 20240131), files without a date come last.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{
+				sdk.ValidationInclusion{List: []string{"name", "date"}},
+			},
+		},
+		"archive.path": {
+			Default:     "",
+			Description: "path is a directory containing archived responses as .json files, or a glob pattern matching them.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{},
+		},
+		"enrichment.concurrency": {
+			Default:     "4",
+			Description: "concurrency is the maximum number of player and team details requested at once while the dimension tables are refreshed.",
+			Type:        sdk.ParameterTypeInt,
+			Validations: []sdk.Validation{},
+		},
+		"enrichment.enabled": {
+			Default:     "false",
+			Description: "enabled joins player and team dimension columns onto every row that contains a PLAYER_ID.",
+			Type:        sdk.ParameterTypeBool,
+			Validations: []sdk.Validation{},
+		},
+		"enrichment.refreshPeriod": {
+			Default:     "24h",
+			Description: "refreshPeriod is how often the cached dimension tables are refreshed from stats.nba.com.",
+			Type:        sdk.ParameterTypeDuration,
+			Validations: []sdk.Validation{},
+		},
+		"format": {
+			Default:     "response",
+			Description: "format selects how stats are emitted: \"response\" emits the whole response of every request as one raw record, \"rows\" decodes the response incrementally and emits a structured record for every row.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{
+				sdk.ValidationInclusion{List: []string{"response", "rows"}},
+			},
+		},
+		"http.cache.dir": {
+			Default:     "",
+			Description: "dir is the directory responses are cached in. Sources using the same directory share the cached responses. If empty, responses aren't cached.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{},
+		},
+		"http.cache.endpointTTL": {
+			Default:     "",
+			Description: "endpointTTL overrides the TTL of single endpoints, as a list of \"endpoint=duration\" pairs, e.g. \"commonallplayers=24h\".",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{},
+		},
+		"http.cache.maxAge": {
+			Default:     "168h",
+			Description: "maxAge is how long a cached response is kept after it was last downloaded or revalidated, older responses are deleted. 0 keeps responses forever.",
+			Type:        sdk.ParameterTypeDuration,
+			Validations: []sdk.Validation{},
+		},
+		"http.cache.ttl": {
+			Default:     "5m",
+			Description: "ttl is how long a cached response is used without asking stats.nba.com if it changed, unless the response specifies it with a Cache-Control header.",
+			Type:        sdk.ParameterTypeDuration,
+			Validations: []sdk.Validation{},
+		},
+		"http.cassette.dir": {
+			Default:     "",
+			Description: "dir is the cassette directory.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{},
+		},
+		"http.cassette.mode": {
+			Default:     "off",
+			Description: "mode is \"off\", \"record\" to record every request and response to the cassette, or \"replay\" to serve all requests from the cassette without any network access.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{
+				sdk.ValidationInclusion{List: []string{"off", "record", "replay"}},
+			},
+		},
+		"http.circuitBreaker.failureThreshold": {
+			Default:     "5",
+			Description: "failureThreshold is the number of consecutive failed requests after which the circuit opens. 0 disables the circuit breaker.",
+			Type:        sdk.ParameterTypeInt,
+			Validations: []sdk.Validation{},
+		},
+		"http.circuitBreaker.halfOpenProbes": {
+			Default:     "1",
+			Description: "halfOpenProbes is the number of probe requests let through after the circuit was open, all of them have to succeed to close it again.",
+			Type:        sdk.ParameterTypeInt,
+			Validations: []sdk.Validation{},
+		},
+		"http.circuitBreaker.openDuration": {
+			Default:     "1m",
+			Description: "openDuration is how long the circuit stays open before probe requests are let through.",
+			Type:        sdk.ParameterTypeDuration,
+			Validations: []sdk.Validation{},
+		},
+		"http.connectTimeout": {
+			Default:     "10s",
+			Description: "connectTimeout is the maximum time to wait for a connection, including the TLS handshake.",
+			Type:        sdk.ParameterTypeDuration,
+			Validations: []sdk.Validation{},
+		},
+		"http.headerProfile": {
+			Default:     "stats",
+			Description: "headerProfile is the named set of headers sent with every request, one of stats, browser or minimal.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{
+				sdk.ValidationInclusion{List: []string{"stats", "browser", "minimal"}},
+			},
+		},
+		"http.headers": {
+			Default:     "",
+			Description: "headers are additional headers sent with every request, one \"Name: value\" pair per line. They override headers of the profile.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{},
+		},
+		"http.idleConnTimeout": {
+			Default:     "90s",
+			Description: "idleConnTimeout is how long an idle keep-alive connection is kept open.",
+			Type:        sdk.ParameterTypeDuration,
+			Validations: []sdk.Validation{},
+		},
+		"http.maxIdleConns": {
+			Default:     "10",
+			Description: "maxIdleConns is the maximum number of idle keep-alive connections.",
+			Type:        sdk.ParameterTypeInt,
+			Validations: []sdk.Validation{},
+		},
+		"http.maxResponseSize": {
+			Default:     "0",
+			Description: "maxResponseSize is the maximum size of a decoded response body in bytes, larger responses are rejected. 0 means no limit.",
+			Type:        sdk.ParameterTypeInt,
+			Validations: []sdk.Validation{},
+		},
+		"http.proxy.password": {
+			Default:     "",
+			Description: "password used to authenticate with the proxy.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{},
+		},
+		"http.proxy.url": {
+			Default:     "",
+			Description: "url of the proxy, the scheme must be http, https or socks5. If empty, the proxy is taken from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{},
+		},
+		"http.proxy.username": {
+			Default:     "",
+			Description: "username used to authenticate with the proxy.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{},
+		},
+		"http.requestTimeout": {
+			Default:     "30s",
+			Description: "requestTimeout is the maximum time to wait for the response headers of a request. Reading the response body isn't limited, since responses are streamed while their records are read.",
+			Type:        sdk.ParameterTypeDuration,
+			Validations: []sdk.Validation{},
+		},
+		"http.tls.caFile": {
+			Default:     "",
+			Description: "caFile is the path to a PEM encoded CA bundle trusted in addition to the system certificate pool.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{},
+		},
+		"http.tls.certFile": {
+			Default:     "",
+			Description: "certFile is the path to a PEM encoded client certificate.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{},
+		},
+		"http.tls.keyFile": {
+			Default:     "",
+			Description: "keyFile is the path to the PEM encoded private key of the client certificate.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{},
+		},
+		"http.userAgents": {
+			Default:     "",
+			Description: "userAgents is a list of user agents, one per line, that requests rotate through. If empty, the user agent of the header profile is used.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{},
+		},
+		"league": {
+			Default:     "nba",
+			Description: "league is the league to query, one of nba, wnba or gleague.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{
+				sdk.ValidationInclusion{List: []string{"nba", "wnba", "gleague"}},
+			},
+		},
+		"mode": {
+			Default:     "stats",
+			Description: "mode selects what the source emits: \"stats\" emits the tracking stats on every poll, \"roster\" emits a CDC record whenever a player joins or leaves a team roster or changes jersey number or position, \"archive\" emits the stats of archived responses read from files.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{
+				sdk.ValidationInclusion{List: []string{"stats", "roster", "archive"}},
+			},
+		},
 		"per_mode": {
 			Default:     "PerGame",
 			Description: "per_mode determines if the stats to be queried should be the per game average or the cumulative totals",
@@ -23,5 +221,17 @@ func (Config) Parameters() map[string]sdk.Parameter {
 			Type:        sdk.ParameterTypeDuration,
 			Validations: []sdk.Validation{},
 		},
+		"season": {
+			Default:     "current",
+			Description: "season to query, formatted as YYYY-YY for the NBA and G League and as YYYY for the WNBA. The value \"current\" follows the league calendar and rolls over to the next season while the pipeline is running.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{},
+		},
+		"windows": {
+			Default:     "season",
+			Description: "windows is a list of windows the stats are aggregated over on every poll: \"season\" for the whole season, \"last<N>\" for the last N games or \"last<N>d\" for the last N days.",
+			Type:        sdk.ParameterTypeString,
+			Validations: []sdk.Validation{},
+		},
 	}
 }
@@ -0,0 +1,440 @@
+package nbastats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// hiveDefaultPartition is the partition value Hive uses for missing values.
+const hiveDefaultPartition = "__HIVE_DEFAULT_PARTITION__"
+
+// partitionColumns are the Hive partition columns and the record metadata
+// keys their values are taken from, in directory order.
+var partitionColumns = []struct {
+	name        string
+	metadataKey string
+}{
+	{"league", metadataLeague},
+	{"season", metadataSeason},
+	{"season_type", metadataSeasonType},
+	{"measure_type", metadataMeasureType},
+	{"date", metadataDate},
+}
+
+// ParquetConfig configures the Parquet files written by the destination.
+type ParquetConfig struct {
+	// MaxRecords is the number of records after which a file is completed
+	// and a new one is started. Files are completed after every batch
+	// regardless.
+	MaxRecords int `json:"maxRecords" default:"1000000"`
+	// MaxFileSize is the approximate size in bytes after which a file is
+	// completed and a new one is started.
+	MaxFileSize int64 `json:"maxFileSize" default:"134217728"`
+}
+
+// parquetWriter writes the rows of incoming records to Parquet files, laid
+// out in Hive style partitions below a directory per collection.
+type parquetWriter struct {
+	dir    string
+	config ParquetConfig
+	names  nameTemplate
+	files  map[string]*parquetFile
+	seq    int
+}
+
+func newParquetWriter(dir string, config ParquetConfig, names nameTemplate) (*parquetWriter, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output dir: %w", err)
+	}
+	return &parquetWriter{dir: dir, config: config, names: names, files: make(map[string]*parquetFile)}, nil
+}
+
+// write writes the rows of the records and completes all files they were
+// written to. Records are acknowledged as soon as write returns, so no
+// acknowledged row is ever left in an incomplete file, e.g. of a date
+// partition that doesn't receive any more rows.
+func (w *parquetWriter) write(ctx context.Context, records []sdk.Record) (int, error) {
+	n, err := w.writeRows(ctx, records)
+	closeErr := w.close()
+	if closeErr != nil {
+		return 0, fmt.Errorf("failed to complete parquet files: %w", closeErr)
+	}
+	return n, err
+}
+
+func (w *parquetWriter) writeRows(ctx context.Context, records []sdk.Record) (int, error) {
+	for i, rec := range records {
+		fields, err := recordFields(rec)
+		if err != nil {
+			return i, fmt.Errorf("invalid record %d: %w", i, err)
+		}
+		if fields == nil {
+			sdk.Logger(ctx).Debug().Str("operation", rec.Operation.String()).Msg("skipping record without payload")
+			continue
+		}
+		err = w.writeRow(ctx, rec, fields)
+		if err != nil {
+			return i, fmt.Errorf("failed to write record %d: %w", i, err)
+		}
+	}
+	return len(records), nil
+}
+
+// writeRow writes the row to the open file of the record's partition,
+// completing the file first if the row doesn't fit its schema or the file is
+// full.
+func (w *parquetWriter) writeRow(ctx context.Context, rec sdk.Record, fields sdk.StructuredData) error {
+	partition := partitionDir(rec)
+	f, ok := w.files[partition]
+	if ok && (!f.schema.fits(fields) || f.full(w.config)) {
+		delete(w.files, partition)
+		err := f.complete()
+		if err != nil {
+			return err
+		}
+		sdk.Logger(ctx).Debug().Str("file", f.path).Int("records", f.records).Msg("completed parquet file")
+		ok = false
+	}
+	if !ok {
+		var err error
+		f, err = w.create(rec, partition, inferParquetSchema(fields))
+		if err != nil {
+			return err
+		}
+		w.files[partition] = f
+	}
+	return f.write(fields)
+}
+
+// create starts a new file for the record in the given partition.
+func (w *parquetWriter) create(rec sdk.Record, partition string, schema parquetSchema) (*parquetFile, error) {
+	w.seq++
+	name, err := w.names.fileName(rec, time.Now(), w.seq)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(w.dir, partition)
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create partition dir: %w", err)
+	}
+	return createParquetFile(filepath.Join(dir, name+".parquet"), schema)
+}
+
+func (w *parquetWriter) close() error {
+	var errs []error
+	for partition, f := range w.files {
+		errs = append(errs, f.complete())
+		delete(w.files, partition)
+	}
+	return errors.Join(errs...)
+}
+
+// partitionDir returns the directory of the record relative to the output
+// directory: <collection>/league=<league>/season=<season>/...
+func partitionDir(rec sdk.Record) string {
+	return partitionPath(rec, false)
+}
+
+// snapshotPartitionDir returns the directory of the snapshot of the record
+// relative to the output directory. Snapshots aren't partitioned by date,
+// so that the snapshot of a day replaces the snapshot of the previous day.
+func snapshotPartitionDir(rec sdk.Record) string {
+	return partitionPath(rec, true)
+}
+
+// partitionPath returns the partition directory of the record, without the
+// date partition if skipDate is true.
+func partitionPath(rec sdk.Record, skipDate bool) string {
+	parts := []string{fileName(recordCollection(rec))}
+	for _, col := range partitionColumns {
+		if skipDate && col.metadataKey == metadataDate {
+			continue
+		}
+		value := rec.Metadata[col.metadataKey]
+		if value == "" {
+			value = hiveDefaultPartition
+		}
+		parts = append(parts, col.name+"="+escapePartitionValue(value))
+	}
+	return filepath.Join(parts...)
+}
+
+// escapePartitionValue escapes the characters Hive escapes in partition
+// values.
+func escapePartitionValue(v string) string {
+	var sb strings.Builder
+	for _, r := range v {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune("\"#%'*/:=?\\{[]^", r) {
+			fmt.Fprintf(&sb, "%%%02X", r)
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// parquetFile is a Parquet file being written. It is written next to its
+// final path and only renamed once it is complete, so readers of the
+// partition never see an incomplete file.
+type parquetFile struct {
+	path    string
+	schema  parquetSchema
+	file    *os.File
+	counter *countingWriter
+	pw      *writer.JSONWriter
+	records int
+}
+
+func createParquetFile(path string, schema parquetSchema) (*parquetFile, error) {
+	file, err := os.Create(inProgressPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create parquet file: %w", err)
+	}
+	counter := &countingWriter{w: file}
+	pw, err := writer.NewJSONWriterFromWriter(schema.json(), counter, 1)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("failed to create parquet writer: %w", err)
+	}
+	return &parquetFile{path: path, schema: schema, file: file, counter: counter, pw: pw}, nil
+}
+
+// inProgressPath returns the path a file is written to until it is
+// complete. Hive, Spark and most other readers ignore files starting with a
+// dot.
+func inProgressPath(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".inprogress")
+}
+
+func (f *parquetFile) write(fields sdk.StructuredData) error {
+	row := make(map[string]interface{}, len(fields))
+	for name, v := range fields {
+		row[name] = f.schema.value(name, v)
+	}
+	raw, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	err = f.pw.Write(string(raw))
+	if err != nil {
+		return err
+	}
+	f.records++
+	return nil
+}
+
+// full returns true if the file reached the configured number of records
+// or size.
+func (f *parquetFile) full(config ParquetConfig) bool {
+	if config.MaxRecords > 0 && f.records >= config.MaxRecords {
+		return true
+	}
+	return config.MaxFileSize > 0 && f.counter.n+f.pw.ObjsSize >= config.MaxFileSize
+}
+
+// complete writes the footer and moves the file to its final path.
+func (f *parquetFile) complete() error {
+	err := f.pw.WriteStop()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to complete %q: %w", f.path, err)
+	}
+	err = os.Rename(f.file.Name(), f.path)
+	if err != nil {
+		return fmt.Errorf("failed to complete %q: %w", f.path, err)
+	}
+	return nil
+}
+
+// parquetSnapshot is the Parquet file of a snapshot. The file is created
+// with the first row, its schema is inferred from that row.
+type parquetSnapshot struct {
+	path string
+	file *parquetFile
+}
+
+func newParquetSnapshotWriter(dir string, names nameTemplate) (*snapshotWriter, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output dir: %w", err)
+	}
+	return newSnapshotWriter(names, func(rec sdk.Record, name string) (snapshotFile, error) {
+		return &parquetSnapshot{path: filepath.Join(dir, snapshotPartitionDir(rec), name+".parquet")}, nil
+	}), nil
+}
+
+func (s *parquetSnapshot) write(rec sdk.Record) error {
+	fields, err := recordFields(rec)
+	if err != nil || fields == nil {
+		return err
+	}
+	if s.file == nil {
+		err = os.MkdirAll(filepath.Dir(s.path), 0o755)
+		if err != nil {
+			return fmt.Errorf("failed to create partition dir: %w", err)
+		}
+		s.file, err = createParquetFile(s.path, inferParquetSchema(fields))
+		if err != nil {
+			return err
+		}
+	}
+	if !s.file.schema.fits(fields) {
+		return errors.New("row doesn't fit the schema inferred from the first row of the snapshot")
+	}
+	return s.file.write(fields)
+}
+
+func (s *parquetSnapshot) complete() error {
+	if s.file == nil {
+		return nil
+	}
+	return s.file.complete()
+}
+
+func (s *parquetSnapshot) discard() error {
+	if s.file == nil {
+		return nil
+	}
+	return discardFile(s.file.file)
+}
+
+// parquetSchema maps the columns of a file to their Parquet type. All
+// columns are optional, rows missing a column store null.
+type parquetSchema struct {
+	columns []string
+	types   map[string]string
+}
+
+// Parquet types of inferred columns.
+const (
+	parquetInt64  = "INT64"
+	parquetDouble = "DOUBLE"
+	parquetBool   = "BOOLEAN"
+	parquetString = "BYTE_ARRAY"
+)
+
+// inferParquetSchema infers the schema of a file from its first row. IDs
+// are stored as integers, since stats.nba.com returns all numbers as floats,
+// all other numbers as doubles. Columns that are null in the first row and
+// nested values are stored as strings.
+func inferParquetSchema(fields sdk.StructuredData) parquetSchema {
+	s := parquetSchema{columns: columnOrder(fields), types: make(map[string]string, len(fields))}
+	for name, v := range fields {
+		s.types[name] = parquetType(name, v)
+	}
+	return s
+}
+
+func parquetType(name string, v interface{}) string {
+	switch v := v.(type) {
+	case float64:
+		if strings.HasSuffix(name, "_ID") && v == math.Trunc(v) {
+			return parquetInt64
+		}
+		return parquetDouble
+	case float32:
+		return parquetDouble
+	case int, int32, int64:
+		if strings.HasSuffix(name, "_ID") {
+			return parquetInt64
+		}
+		return parquetDouble
+	case bool:
+		return parquetBool
+	default:
+		return parquetString
+	}
+}
+
+// fits returns true if all fields of the row are columns of the schema and
+// have a compatible type.
+func (s parquetSchema) fits(fields sdk.StructuredData) bool {
+	for name, v := range fields {
+		t, ok := s.types[name]
+		if !ok {
+			return false
+		}
+		if v == nil || t == parquetString {
+			continue
+		}
+		switch parquetType(name, v) {
+		case t:
+		case parquetInt64:
+			// integral IDs can be stored in a double column
+			if t != parquetDouble {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// value converts a field value to the JSON value written to its column.
+func (s parquetSchema) value(name string, v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	switch s.types[name] {
+	case parquetString:
+		if str, ok := v.(string); ok {
+			return str
+		}
+		raw, _ := json.Marshal(v)
+		return string(raw)
+	case parquetInt64:
+		if f, ok := v.(float64); ok {
+			return int64(f)
+		}
+	}
+	return v
+}
+
+// json returns the schema in the JSON format of parquet-go.
+func (s parquetSchema) json() string {
+	type field struct {
+		Tag string
+	}
+	root := struct {
+		Tag    string
+		Fields []field
+	}{Tag: "name=parquet_go_root, repetitiontype=REQUIRED"}
+	for _, name := range s.columns {
+		tag := fmt.Sprintf("name=%s, type=%s, repetitiontype=OPTIONAL", name, s.types[name])
+		if s.types[name] == parquetString {
+			tag += ", convertedtype=UTF8"
+		}
+		root.Fields = append(root.Fields, field{Tag: tag})
+	}
+	raw, _ := json.Marshal(root)
+	return string(raw)
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,261 @@
+package nbastats
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// PrometheusConfig configures the metrics exposed with format prometheus.
+type PrometheusConfig struct {
+	// Address is the address the metrics endpoint listens on.
+	Address string `json:"address" default:"localhost:9464"`
+	// Path is the path of the metrics endpoint.
+	Path string `json:"path" default:"/metrics"`
+	// Prefix is prepended to the metric names, which are the lower case
+	// column names.
+	Prefix string `json:"prefix" default:"nba_"`
+	// Columns are the numeric columns exposed as gauges. Empty exposes all
+	// numeric columns except IDs.
+	Columns []string `json:"columns"`
+}
+
+// metricLabels are the labels of every gauge, in exposition order.
+var metricLabels = []string{"collection", "season", "measure_type", "per_mode", "player_id", "player", "team"}
+
+var invalidMetricChars = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+
+// metricsWriter turns the numeric fields of rows into gauges, exposed in
+// the Prometheus text format. Every row contributes one sample per gauge
+// with the latest value of its column.
+type metricsWriter struct {
+	config  PrometheusConfig
+	columns map[string]bool
+	server  *http.Server
+
+	mu sync.RWMutex
+	// rows are the samples of the latest rows, by label set. Rows with the
+	// same label set, e.g. rows without player, share their samples.
+	rows map[string]metricRow
+	// series is the label set of every row, by metricRowID.
+	series map[string]string
+	// refs counts the rows of every label set.
+	refs map[string]int
+}
+
+// metricRow are the samples of a row.
+type metricRow struct {
+	// labels is the formatted label set of the row.
+	labels string
+	// values are the sample values by metric name.
+	values map[string]float64
+}
+
+func newMetricsWriter(config PrometheusConfig) (*metricsWriter, error) {
+	w := &metricsWriter{
+		config: config,
+		rows:   make(map[string]metricRow),
+		series: make(map[string]string),
+		refs:   make(map[string]int),
+	}
+	if len(config.Columns) > 0 {
+		w.columns = make(map[string]bool, len(config.Columns))
+		for _, col := range config.Columns {
+			w.columns[strings.TrimSpace(col)] = true
+		}
+	}
+
+	ln, err := net.Listen("tcp", config.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %q: %w", config.Address, err)
+	}
+	path := config.Path
+	if path == "" {
+		path = "/metrics"
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, w.serveMetrics)
+	w.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		_ = w.server.Serve(ln)
+	}()
+	return w, nil
+}
+
+func (w *metricsWriter) write(ctx context.Context, records []sdk.Record) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i, rec := range records {
+		key, err := recordKey(rec)
+		if err != nil {
+			return i, fmt.Errorf("invalid record %d: %w", i, err)
+		}
+		id := metricRowID(rec, key)
+		if rec.Operation == sdk.OperationDelete {
+			if labels, ok := w.series[id]; ok {
+				delete(w.series, id)
+				w.release(labels)
+			}
+			continue
+		}
+		fields, err := recordFields(rec)
+		if err != nil {
+			return i, fmt.Errorf("invalid record %d: %w", i, err)
+		}
+		if fields == nil {
+			continue
+		}
+		row := w.metricRow(rec, fields)
+		// the labels of a row change e.g. when a player is traded, the
+		// series of the old labels are removed
+		if labels, ok := w.series[id]; !ok || labels != row.labels {
+			if ok {
+				w.release(labels)
+			}
+			w.series[id] = row.labels
+			w.refs[row.labels]++
+		}
+		w.rows[row.labels] = row
+	}
+	return len(records), nil
+}
+
+// release removes a row from its label set, the samples are removed with
+// the last row.
+func (w *metricsWriter) release(labels string) {
+	w.refs[labels]--
+	if w.refs[labels] <= 0 {
+		delete(w.refs, labels)
+		delete(w.rows, labels)
+	}
+}
+
+// metricRowID identifies the row of a record. Rows of the same key with
+// another season, measure type or per mode are separate rows.
+func metricRowID(rec sdk.Record, key string) string {
+	return strings.Join([]string{
+		recordCollection(rec),
+		rec.Metadata[metadataSeason],
+		rec.Metadata[metadataMeasureType],
+		rec.Metadata[metadataPerMode],
+		key,
+	}, "\x00")
+}
+
+// metricRow returns the samples of the row.
+func (w *metricsWriter) metricRow(rec sdk.Record, fields sdk.StructuredData) metricRow {
+	labels := map[string]string{
+		"collection":   recordCollection(rec),
+		"season":       rec.Metadata[metadataSeason],
+		"measure_type": rec.Metadata[metadataMeasureType],
+		"per_mode":     rec.Metadata[metadataPerMode],
+	}
+	if id, ok := toInt(fields["PLAYER_ID"]); ok {
+		labels["player_id"] = strconv.Itoa(id)
+	}
+	labels["player"], _ = fields["PLAYER_NAME"].(string)
+	labels["team"], _ = fields["TEAM_ABBREVIATION"].(string)
+
+	var sb strings.Builder
+	for _, name := range metricLabels {
+		if sb.Len() > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, `%s="%s"`, name, escapeLabelValue(labels[name]))
+	}
+
+	row := metricRow{labels: sb.String(), values: make(map[string]float64)}
+	for column, v := range fields {
+		if w.columns != nil && !w.columns[column] {
+			continue
+		}
+		if w.columns == nil && strings.HasSuffix(column, "_ID") {
+			continue
+		}
+		if f, ok := numericValue(v); ok {
+			row.values[w.metricName(column)] = f
+		}
+	}
+	return row
+}
+
+// metricName returns the name of the gauge of a column.
+func (w *metricsWriter) metricName(column string) string {
+	name := invalidMetricChars.ReplaceAllString(w.config.Prefix+strings.ToLower(column), "_")
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// serveMetrics writes all gauges in the Prometheus text exposition format.
+func (w *metricsWriter) serveMetrics(rw http.ResponseWriter, r *http.Request) {
+	if !allowGet(rw, r) {
+		return
+	}
+	w.mu.RLock()
+	samples := make(map[string][]string)
+	for _, row := range w.rows {
+		for name, v := range row.values {
+			samples[name] = append(samples[name], fmt.Sprintf("%s{%s} %s\n", name, row.labels, formatSampleValue(v)))
+		}
+	}
+	w.mu.RUnlock()
+
+	names := make([]string, 0, len(samples))
+	for name := range samples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&sb, "# HELP %s Latest value of the stat in the rows written to the NBA stats destination.\n", name)
+		fmt.Fprintf(&sb, "# TYPE %s gauge\n", name)
+		lines := samples[name]
+		sort.Strings(lines)
+		for _, line := range lines {
+			sb.WriteString(line)
+		}
+	}
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = rw.Write([]byte(sb.String()))
+}
+
+func (w *metricsWriter) close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return w.server.Shutdown(ctx)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabelValue escapes backslashes, double quotes and line feeds in a
+// label value, as required by the text format.
+func escapeLabelValue(v string) string {
+	return labelValueEscaper.Replace(v)
+}
+
+// formatSampleValue formats a sample value for the text format.
+func formatSampleValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
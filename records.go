@@ -0,0 +1,223 @@
+package nbastats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// metadataCollection is the OpenCDC record metadata key containing the
+// collection a record belongs to.
+const metadataCollection = "opencdc.collection"
+
+const (
+	// metadataPerMode is the record metadata key containing the per mode of
+	// the stats in the record, e.g. "PerGame".
+	metadataPerMode = "nbastats.per_mode"
+	// metadataSnapshot is the record metadata key identifying the snapshot
+	// a row belongs to: all rows of a result set in one response.
+	metadataSnapshot = "nbastats.snapshot"
+	// metadataSnapshotRow is the record metadata key containing the index
+	// of the row in its snapshot.
+	metadataSnapshotRow = "nbastats.snapshot.row"
+	// metadataSnapshotComplete is the record metadata key marking the last
+	// row of a snapshot.
+	metadataSnapshotComplete = "nbastats.snapshot.complete"
+)
+
+const (
+	// formatResponse emits the whole response of a request as a single raw
+	// record.
+	formatResponse = "response"
+	// formatRows emits a structured record for every row of a response.
+	formatRows = "rows"
+)
+
+// snapshotTimestampFormat is the format of the poll timestamp that prefixes
+// snapshot IDs and positions of rows.
+const snapshotTimestampFormat = "2006-01-02-150405.000000000"
+
+// rowStream decodes a response row by row and builds a structured record
+// for every row, so that responses never have to be held in memory as a
+// whole. An empty window or per mode is taken from the parameters of the
+// response.
+type rowStream struct {
+	url        string
+	body       io.Closer
+	dec        *resultSetDecoder
+	dimensions *dimensionCache
+
+	window    string
+	perMode   string
+	timestamp string
+	// index is the index of the next row in the response and skip is the
+	// number of rows at the start of the response that aren't returned.
+	index int
+	skip  int
+	// snapshotIndex is the index of the next row in its result set.
+	snapshotIndex int
+	// peeked is the row following the last returned row. Rows are decoded
+	// one ahead to know which row is the last one of its result set.
+	peeked *decodedRow
+}
+
+// decodedRow is a row returned by the decoder.
+type decodedRow struct {
+	rs  *ResultSet
+	row []interface{}
+	err error
+	// snapshotRow is the index of the row in its result set and last is
+	// true for the last row of the result set.
+	snapshotRow int
+	last        bool
+}
+
+func newRowStream(url string, body io.ReadCloser, dimensions *dimensionCache, window, perMode, timestamp string, skip int) *rowStream {
+	return &rowStream{
+		url:        url,
+		body:       body,
+		dec:        newResultSetDecoder(body, url),
+		dimensions: dimensions,
+		window:     window,
+		perMode:    perMode,
+		timestamp:  timestamp,
+		skip:       skip,
+	}
+}
+
+// next returns the record of the next row, or io.EOF once all rows were
+// returned.
+func (st *rowStream) next(ctx context.Context) (sdk.Record, error) {
+	// rows already returned by an earlier stream of the window are skipped
+	for st.index < st.skip {
+		current := st.nextRow()
+		if current.err != nil {
+			return sdk.Record{}, st.rowError(ctx, current.err)
+		}
+		st.index++
+	}
+	current := st.nextRow()
+	if current.err != nil {
+		return sdk.Record{}, st.rowError(ctx, current.err)
+	}
+	rs, row := current.rs, current.row
+
+	fields := make(sdk.StructuredData, len(rs.Headers))
+	for i, h := range rs.Headers {
+		if i < len(row) {
+			fields[h] = row[i]
+		}
+	}
+	if playerID, ok := fields["PLAYER_ID"]; ok && st.dimensions != nil {
+		values, err := st.dimensions.values(ctx, playerID, fields["TEAM_ID"])
+		if err != nil {
+			return sdk.Record{}, fmt.Errorf("failed to enrich row: %w", err)
+		}
+		for i, col := range dimensionColumns {
+			fields[col] = values[i]
+		}
+	}
+
+	window, perMode := st.window, st.perMode
+	if window == "" {
+		window = windowFromParameters(st.dec.parameters)
+	}
+	if perMode == "" {
+		perMode, _ = st.dec.parameters["PerMode"].(string)
+	}
+
+	collection := rs.Name
+	if window != windowSeason {
+		collection += "_" + window
+	}
+	prefix := fmt.Sprintf("%s_%s_%s", st.timestamp, perMode, window)
+	position := fmt.Sprintf("%s_%d", prefix, st.index)
+	key := rowKey(fields, st.index)
+	st.index++
+
+	metadata := sdk.Metadata{
+		metadataCollection:  collection,
+		metadataWindow:      window,
+		metadataPerMode:     perMode,
+		metadataSnapshot:    prefix + "_" + collection,
+		metadataSnapshotRow: strconv.Itoa(current.snapshotRow),
+	}
+	if current.last {
+		metadata[metadataSnapshotComplete] = "true"
+	}
+
+	return sdk.Util.Source.NewRecordCreate(
+		sdk.Position(position),
+		metadata,
+		key,
+		fields,
+	), nil
+}
+
+// nextRow returns the next decoded row together with its index in its
+// result set and whether it is the last row of the result set.
+func (st *rowStream) nextRow() *decodedRow {
+	current := st.peeked
+	if current == nil {
+		current = st.decode()
+	}
+	st.peeked = nil
+	if current.err != nil {
+		return current
+	}
+
+	current.snapshotRow = st.snapshotIndex
+	st.snapshotIndex++
+	// a decode error of the following row is returned by the next call, the
+	// row isn't the last one of its snapshot then
+	st.peeked = st.decode()
+	if next := st.peeked; errors.Is(next.err, io.EOF) || (next.err == nil && next.rs != current.rs) {
+		current.last = true
+		st.snapshotIndex = 0
+	}
+	return current
+}
+
+// rowError returns the error next fails with, io.EOF is returned as is.
+func (st *rowStream) rowError(ctx context.Context, err error) error {
+	if errors.Is(err, io.EOF) {
+		return err
+	}
+	return decodeError(ctx, st.url, err)
+}
+
+func (st *rowStream) decode() *decodedRow {
+	rs, row, err := st.dec.next()
+	return &decodedRow{rs: rs, row: row, err: err}
+}
+
+// returned returns the number of rows at the start of the response that
+// were returned, by this stream or by the earlier streams it skipped.
+func (st *rowStream) returned() int {
+	if st.index < st.skip {
+		return st.skip
+	}
+	return st.index
+}
+
+func (st *rowStream) close() error {
+	return st.body.Close()
+}
+
+// rowKey identifies a row by its PLAYER_ID or, for team stats, its TEAM_ID.
+// Rows without either are identified by their index in the response.
+func rowKey(fields sdk.StructuredData, index int) sdk.Data {
+	for _, id := range []string{"PLAYER_ID", "TEAM_ID"} {
+		if v, ok := fields[id]; ok {
+			if n, ok := toInt(v); ok {
+				return sdk.StructuredData{id: n}
+			}
+			return sdk.StructuredData{id: v}
+		}
+	}
+	return sdk.StructuredData{"ROW": index}
+}
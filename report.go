@@ -0,0 +1,295 @@
+package nbastats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Masterminds/sprig/v3"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// ReportConfig configures the reports rendered with format report.
+type ReportConfig struct {
+	// Template is the Go template file reports are rendered with. The
+	// extension of the reports is the extension of the template without a
+	// trailing ".tmpl", templates of HTML reports are executed with
+	// html/template.
+	Template string `json:"template"`
+}
+
+// templateSuffixes are stripped from the template file name to get the
+// extension of the reports.
+var templateSuffixes = []string{".tmpl", ".tpl", ".gotmpl"}
+
+// reportExtension returns the extension of the reports rendered with the
+// template file, ".md" if the template doesn't have one.
+func reportExtension(template string) string {
+	name := filepath.Base(template)
+	for _, suffix := range templateSuffixes {
+		if trimmed := strings.TrimSuffix(name, suffix); trimmed != name {
+			name = trimmed
+			break
+		}
+	}
+	if ext := filepath.Ext(name); ext != "" {
+		return ext
+	}
+	return ".md"
+}
+
+// reportTemplate is a parsed text/template or html/template template.
+type reportTemplate interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// parseReportTemplate parses the template file with the sprig functions and
+// the report functions.
+func parseReportTemplate(path, ext string) (reportTemplate, error) {
+	name := filepath.Base(path)
+	if ext == ".html" || ext == ".htm" {
+		funcs := sprig.HtmlFuncMap()
+		for key, fn := range reportFuncs {
+			funcs[key] = fn
+		}
+		return htmltemplate.New(name).Funcs(funcs).ParseFiles(path)
+	}
+	funcs := sprig.TxtFuncMap()
+	for key, fn := range reportFuncs {
+		funcs[key] = fn
+	}
+	return template.New(name).Funcs(funcs).ParseFiles(path)
+}
+
+// reportFuncs are the functions report templates can use in addition to the
+// sprig functions.
+var reportFuncs = map[string]interface{}{
+	"sortBy": func(column string, rows []sdk.StructuredData) []sdk.StructuredData {
+		return sortRows(rows, column, false)
+	},
+	"sortAscBy": func(column string, rows []sdk.StructuredData) []sdk.StructuredData {
+		return sortRows(rows, column, true)
+	},
+	"top": func(n int, rows []sdk.StructuredData) []sdk.StructuredData {
+		if n < len(rows) {
+			return rows[:n]
+		}
+		return rows
+	},
+}
+
+// sortRows returns the rows sorted by the numeric values of the column, in
+// descending order unless ascending is set. Rows without a numeric value in
+// the column are left out.
+func sortRows(rows []sdk.StructuredData, column string, ascending bool) []sdk.StructuredData {
+	sorted := make([]sdk.StructuredData, 0, len(rows))
+	for _, row := range rows {
+		if _, ok := numericValue(row[column]); ok {
+			sorted = append(sorted, row)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		vi, _ := numericValue(sorted[i][column])
+		vj, _ := numericValue(sorted[j][column])
+		if ascending {
+			return vi < vj
+		}
+		return vi > vj
+	})
+	return sorted
+}
+
+// reportData is the data report templates are executed with.
+type reportData struct {
+	// Generated is the time the report was rendered.
+	Generated time.Time
+	// Date is the date the stats of the snapshot that triggered the report
+	// were fetched, formatted as YYYY-MM-DD.
+	Date string
+	// Snapshots are the latest complete snapshots, sorted by name.
+	Snapshots []reportSnapshot
+}
+
+// Snapshot returns the latest snapshot of the collection, e.g.
+// "LeagueDashPtStats_last7d". If measure types are given, the snapshot has
+// to have one of them. It returns an empty snapshot if there is none.
+func (d reportData) Snapshot(collection string, measureTypes ...string) reportSnapshot {
+	for _, s := range d.Snapshots {
+		if s.Collection != collection {
+			continue
+		}
+		if len(measureTypes) == 0 {
+			return s
+		}
+		for _, mt := range measureTypes {
+			if s.MeasureType == mt {
+				return s
+			}
+		}
+	}
+	return reportSnapshot{Collection: collection}
+}
+
+// reportSnapshot is a complete snapshot of rows in report templates.
+type reportSnapshot struct {
+	// Name is the name of the snapshot, see the default snapshot name
+	// template.
+	Name        string
+	Collection  string
+	League      string
+	Season      string
+	SeasonType  string
+	MeasureType string
+	PerMode     string
+	Window      string
+	Date        string
+	// Rows are the rows of the snapshot in the order they were emitted.
+	Rows []sdk.StructuredData
+}
+
+// reportWriter keeps the latest complete snapshot of every collection,
+// season, measure type and per mode, and renders a report with all of them
+// whenever a batch completes a snapshot. Reports are named by the name
+// template executed with the record completing the snapshot and replaced
+// atomically, so the daily report is rendered again with every poll.
+type reportWriter struct {
+	dir       string
+	ext       string
+	names     nameTemplate
+	tmpl      reportTemplate
+	snapshots *snapshotWriter
+	// latest are the latest complete snapshots by name.
+	latest map[string]reportSnapshot
+	// completed is the last record that completed a snapshot since the last
+	// report was rendered, nil if there is none.
+	completed *sdk.Record
+}
+
+func newReportWriter(dir string, config ReportConfig, names nameTemplate) (*reportWriter, error) {
+	ext := reportExtension(config.Template)
+	tmpl, err := parseReportTemplate(config.Template, ext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse report template: %w", err)
+	}
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output dir: %w", err)
+	}
+	snapshotNames, err := parseNameTemplate(snapshotNameTemplate, "")
+	if err != nil {
+		return nil, err
+	}
+
+	w := &reportWriter{
+		dir:    dir,
+		ext:    ext,
+		names:  names,
+		tmpl:   tmpl,
+		latest: make(map[string]reportSnapshot),
+	}
+	w.snapshots = newSnapshotWriter(snapshotNames, func(rec sdk.Record, name string) (snapshotFile, error) {
+		return &pendingReportSnapshot{writer: w, snapshot: reportSnapshot{
+			Name:        name,
+			Collection:  recordCollection(rec),
+			League:      rec.Metadata[metadataLeague],
+			Season:      rec.Metadata[metadataSeason],
+			SeasonType:  rec.Metadata[metadataSeasonType],
+			MeasureType: rec.Metadata[metadataMeasureType],
+			PerMode:     rec.Metadata[metadataPerMode],
+			Window:      rec.Metadata[metadataWindow],
+			Date:        rec.Metadata[metadataDate],
+		}}, nil
+	})
+	return w, nil
+}
+
+func (w *reportWriter) write(ctx context.Context, records []sdk.Record) (int, error) {
+	n, err := w.snapshots.write(ctx, records)
+	if w.completed != nil {
+		rec := *w.completed
+		w.completed = nil
+		if renderErr := w.render(ctx, rec); renderErr != nil {
+			return 0, errors.Join(err, renderErr)
+		}
+	}
+	return n, err
+}
+
+// render renders the report named after the record with the latest
+// snapshots.
+func (w *reportWriter) render(ctx context.Context, rec sdk.Record) error {
+	name, err := w.names.fileName(rec, time.Now(), 0)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(w.dir, name+w.ext)
+
+	data := reportData{
+		Generated: time.Now(),
+		Date:      rec.Metadata[metadataDate],
+		Snapshots: make([]reportSnapshot, 0, len(w.latest)),
+	}
+	for _, s := range w.latest {
+		data.Snapshots = append(data.Snapshots, s)
+	}
+	sort.Slice(data.Snapshots, func(i, j int) bool { return data.Snapshots[i].Name < data.Snapshots[j].Name })
+
+	var sb strings.Builder
+	err = w.tmpl.Execute(&sb, data)
+	if err != nil {
+		return fmt.Errorf("failed to render report %q: %w", path, err)
+	}
+	tmp := inProgressPath(path)
+	err = os.WriteFile(tmp, []byte(sb.String()), 0o644)
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write report %q: %w", path, err)
+	}
+	sdk.Logger(ctx).Debug().Str("report", path).Msg("rendered report")
+	return nil
+}
+
+func (w *reportWriter) close() error {
+	return w.snapshots.close()
+}
+
+// pendingReportSnapshot collects the rows of a snapshot in memory and makes
+// it the latest snapshot of its name once it is complete.
+type pendingReportSnapshot struct {
+	writer   *reportWriter
+	snapshot reportSnapshot
+	last     sdk.Record
+}
+
+func (s *pendingReportSnapshot) write(rec sdk.Record) error {
+	s.last = rec
+	if rec.Operation == sdk.OperationDelete {
+		return nil
+	}
+	fields, err := recordFields(rec)
+	if err != nil || fields == nil {
+		return err
+	}
+	s.snapshot.Rows = append(s.snapshot.Rows, fields)
+	return nil
+}
+
+func (s *pendingReportSnapshot) complete() error {
+	s.writer.latest[s.snapshot.Name] = s.snapshot
+	s.writer.completed = &s.last
+	return nil
+}
+
+func (s *pendingReportSnapshot) discard() error {
+	return nil
+}
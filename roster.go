@@ -0,0 +1,293 @@
+package nbastats
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+const (
+	// metadataTeamID is the record metadata key containing the TEAM_ID of
+	// the roster a player is on.
+	metadataTeamID = "nbastats.team_id"
+	// metadataRosterChange is the record metadata key listing the roster
+	// fields that changed (e.g. "team,jersey").
+	metadataRosterChange = "nbastats.roster.change"
+)
+
+// rosterEntry is a single player on a team roster, as returned by
+// commonteamroster.
+type rosterEntry struct {
+	PlayerID int
+	TeamID   int
+	Number   string
+	Position string
+	Row      map[string]interface{}
+}
+
+// rosterTracker polls commonteamroster for every team and turns the
+// differences between two consecutive polls into CDC records keyed by
+// PLAYER_ID.
+type rosterTracker struct {
+	client *statsClient
+	params NBAStatsQueryParams
+
+	// previous is nil until the first poll, after which it contains the
+	// roster entries of every rostered player.
+	previous map[int]rosterEntry
+	// resumed is the roster hash of the position the source was opened
+	// with, empty if it started without one.
+	resumed string
+}
+
+func newRosterTracker(client *statsClient, params NBAStatsQueryParams, pos sdk.Position) *rosterTracker {
+	return &rosterTracker{
+		client:  client,
+		params:  params,
+		resumed: rosterPositionHash(pos),
+	}
+}
+
+// fetchTeamIDs returns the IDs of the teams that play in the tracked season,
+// according to commonteamyears.
+func (t *rosterTracker) fetchTeamIDs(ctx context.Context) ([]int, error) {
+	values := url.Values{}
+	values.Set("LeagueID", t.params.LeagueID)
+
+	data, err := t.client.fetchNBAStatsResponse(ctx, "commonteamyears", values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch teams: %w", err)
+	}
+	rs, ok := data.resultSet("TeamYears")
+	if !ok {
+		return nil, fmt.Errorf("failed to fetch teams: %w", schemaError(buildEndpointURL("commonteamyears", values), "missing result set TeamYears"))
+	}
+
+	year := seasonStartYear(t.params.Season)
+	var ids []int
+	for _, row := range rs.rows() {
+		id, ok := toInt(row["TEAM_ID"])
+		if !ok {
+			continue
+		}
+		minYear, _ := toInt(row["MIN_YEAR"])
+		maxYear, _ := toInt(row["MAX_YEAR"])
+		if minYear <= year && year <= maxYear {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// poll fetches all rosters and returns the records describing what changed
+// since the last poll. The first poll returns a snapshot of every player,
+// unless the source resumed from a position whose roster is unchanged.
+func (t *rosterTracker) poll(ctx context.Context) ([]sdk.Record, error) {
+	teamIDs, err := t.fetchTeamIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	current := make(map[int]rosterEntry)
+	for _, teamID := range teamIDs {
+		entries, err := t.fetchRoster(ctx, teamID)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			current[e.PlayerID] = e
+		}
+	}
+	sdk.Logger(ctx).Info().Int("players", len(current)).Msg("fetched team rosters")
+
+	if t.previous == nil && t.resumed != "" {
+		if t.resumed == rosterHash(current) {
+			sdk.Logger(ctx).Info().Msg("rosters unchanged since the last acknowledged poll")
+			t.previous = current
+		} else {
+			// the changes since the last acknowledged poll are unknown
+			sdk.Logger(ctx).Info().Msg("rosters changed since the last acknowledged poll, emitting a snapshot")
+		}
+		t.resumed = ""
+	}
+	records := diffRosters(t.previous, current, time.Now())
+	t.previous = current
+	return records, nil
+}
+
+// diffRosters returns the records turning the previous rosters into the
+// current ones: creates for players who joined, updates for players whose
+// team, jersey or position changed and deletes for players who left. If
+// previous is nil, it returns a snapshot of every player.
+//
+// The position of every record contains the hash of the rosters the source
+// resumes from: the previous rosters, except for the last record, whose
+// position contains the hash of the current rosters. A source restarted
+// from any position but the last one therefore emits the changes again.
+func diffRosters(previous, current map[int]rosterEntry, now time.Time) []sdk.Record {
+	var records []sdk.Record
+	if previous == nil {
+		for _, id := range sortedPlayerIDs(current) {
+			e := current[id]
+			records = append(records, sdk.Util.Source.NewRecordSnapshot(
+				nil,
+				rosterMetadata(e, ""),
+				rosterKey(id),
+				sdk.StructuredData(e.Row),
+			))
+		}
+	} else {
+		for _, id := range sortedPlayerIDs(current) {
+			after := current[id]
+			before, ok := previous[id]
+			if !ok {
+				records = append(records, sdk.Util.Source.NewRecordCreate(
+					nil,
+					rosterMetadata(after, "joined"),
+					rosterKey(id),
+					sdk.StructuredData(after.Row),
+				))
+				continue
+			}
+			if changes := rosterChanges(before, after); changes != "" {
+				records = append(records, sdk.Util.Source.NewRecordUpdate(
+					nil,
+					rosterMetadata(after, changes),
+					rosterKey(id),
+					sdk.StructuredData(before.Row),
+					sdk.StructuredData(after.Row),
+				))
+			}
+		}
+		for _, id := range sortedPlayerIDs(previous) {
+			before := previous[id]
+			if _, ok := current[id]; !ok {
+				records = append(records, sdk.Util.Source.NewRecordDelete(
+					nil,
+					rosterMetadata(before, "left"),
+					rosterKey(id),
+				))
+			}
+		}
+	}
+
+	previousHash := ""
+	if previous != nil {
+		previousHash = rosterHash(previous)
+	}
+	for i := range records {
+		hash := previousHash
+		if i == len(records)-1 {
+			hash = rosterHash(current)
+		}
+		id, _ := toInt(records[i].Key.(sdk.StructuredData)["PLAYER_ID"])
+		records[i].Position = rosterPosition(now, id, hash)
+	}
+	return records
+}
+
+// fetchRoster returns the roster of a single team.
+func (t *rosterTracker) fetchRoster(ctx context.Context, teamID int) ([]rosterEntry, error) {
+	values := url.Values{}
+	values.Set("LeagueID", t.params.LeagueID)
+	values.Set("Season", t.params.Season)
+	values.Set("TeamID", strconv.Itoa(teamID))
+
+	data, err := t.client.fetchNBAStatsResponse(ctx, "commonteamroster", values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch roster of team %d: %w", teamID, err)
+	}
+	rs, ok := data.resultSet("CommonTeamRoster")
+	if !ok {
+		return nil, fmt.Errorf("failed to fetch roster of team %d: %w", teamID, schemaError(buildEndpointURL("commonteamroster", values), "missing result set CommonTeamRoster"))
+	}
+
+	rows := rs.rows()
+	entries := make([]rosterEntry, 0, len(rows))
+	for _, row := range rows {
+		playerID, ok := toInt(row["PLAYER_ID"])
+		if !ok {
+			continue
+		}
+		entries = append(entries, rosterEntry{
+			PlayerID: playerID,
+			TeamID:   teamID,
+			Number:   fmt.Sprint(row["NUM"]),
+			Position: fmt.Sprint(row["POSITION"]),
+			Row:      row,
+		})
+	}
+	return entries, nil
+}
+
+// rosterChanges returns a comma separated list of the tracked fields that
+// differ between before and after, or an empty string if none do.
+func rosterChanges(before, after rosterEntry) string {
+	var changes []string
+	if before.TeamID != after.TeamID {
+		changes = append(changes, "team")
+	}
+	if before.Number != after.Number {
+		changes = append(changes, "jersey")
+	}
+	if before.Position != after.Position {
+		changes = append(changes, "position")
+	}
+	return strings.Join(changes, ",")
+}
+
+func rosterKey(playerID int) sdk.Data {
+	return sdk.StructuredData{"PLAYER_ID": playerID}
+}
+
+// rosterPosition returns the position of a roster record, it ends with the
+// hash of the rosters the source resumes from.
+func rosterPosition(t time.Time, playerID int, hash string) sdk.Position {
+	return sdk.Position(fmt.Sprintf("roster_%s_%d_%s", t.Format("2006-01-02-150405"), playerID, hash))
+}
+
+// rosterPositionHash returns the roster hash of a position, or an empty
+// string if the position doesn't contain one.
+func rosterPositionHash(pos sdk.Position) string {
+	if !strings.HasPrefix(string(pos), "roster_") {
+		return ""
+	}
+	return string(pos[strings.LastIndex(string(pos), "_")+1:])
+}
+
+// rosterHash returns a hash of the tracked fields of all roster entries.
+func rosterHash(entries map[int]rosterEntry) string {
+	h := sha256.New()
+	for _, id := range sortedPlayerIDs(entries) {
+		e := entries[id]
+		fmt.Fprintf(h, "%d|%d|%s|%s\n", e.PlayerID, e.TeamID, e.Number, e.Position)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+func rosterMetadata(e rosterEntry, change string) sdk.Metadata {
+	m := sdk.Metadata{
+		metadataTeamID: strconv.Itoa(e.TeamID),
+	}
+	if change != "" {
+		m[metadataRosterChange] = change
+	}
+	return m
+}
+
+func sortedPlayerIDs(entries map[int]rosterEntry) []int {
+	ids := make([]int, 0, len(entries))
+	for id := range entries {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
@@ -0,0 +1,167 @@
+package nbastats
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/matryer/is"
+)
+
+func TestRosterChanges(t *testing.T) {
+	before := rosterEntry{PlayerID: 1, TeamID: 10, Number: "30", Position: "G"}
+	testCases := []struct {
+		name  string
+		after rosterEntry
+		want  string
+	}{
+		{"unchanged", before, ""},
+		{"team", rosterEntry{PlayerID: 1, TeamID: 11, Number: "30", Position: "G"}, "team"},
+		{"jersey", rosterEntry{PlayerID: 1, TeamID: 10, Number: "11", Position: "G"}, "jersey"},
+		{"position", rosterEntry{PlayerID: 1, TeamID: 10, Number: "30", Position: "G-F"}, "position"},
+		{"all", rosterEntry{PlayerID: 1, TeamID: 11, Number: "11", Position: "F"}, "team,jersey,position"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(rosterChanges(before, tc.after), tc.want)
+		})
+	}
+}
+
+func TestDiffRosters(t *testing.T) {
+	entry := func(playerID, teamID int, number string) rosterEntry {
+		return rosterEntry{PlayerID: playerID, TeamID: teamID, Number: number, Position: "G",
+			Row: map[string]interface{}{"PLAYER_ID": playerID, "NUM": number}}
+	}
+	roster := func(entries ...rosterEntry) map[int]rosterEntry {
+		m := make(map[int]rosterEntry)
+		for _, e := range entries {
+			m[e.PlayerID] = e
+		}
+		return m
+	}
+	type change struct {
+		op       sdk.Operation
+		playerID int
+		change   string
+	}
+	testCases := []struct {
+		name     string
+		previous map[int]rosterEntry
+		current  map[int]rosterEntry
+		want     []change
+	}{{
+		name:    "first poll",
+		current: roster(entry(2, 10, "1"), entry(1, 10, "2")),
+		want:    []change{{sdk.OperationSnapshot, 1, ""}, {sdk.OperationSnapshot, 2, ""}},
+	}, {
+		name:     "unchanged",
+		previous: roster(entry(1, 10, "2")),
+		current:  roster(entry(1, 10, "2")),
+	}, {
+		name:     "added",
+		previous: roster(entry(1, 10, "2")),
+		current:  roster(entry(1, 10, "2"), entry(3, 11, "5")),
+		want:     []change{{sdk.OperationCreate, 3, "joined"}},
+	}, {
+		name:     "removed",
+		previous: roster(entry(1, 10, "2"), entry(3, 11, "5")),
+		current:  roster(entry(1, 10, "2")),
+		want:     []change{{sdk.OperationDelete, 3, "left"}},
+	}, {
+		name:     "changed",
+		previous: roster(entry(1, 10, "2"), entry(3, 11, "5")),
+		current:  roster(entry(1, 11, "2"), entry(3, 11, "8")),
+		want:     []change{{sdk.OperationUpdate, 1, "team"}, {sdk.OperationUpdate, 3, "jersey"}},
+	}, {
+		name:     "all",
+		previous: roster(entry(1, 10, "2"), entry(3, 11, "5")),
+		current:  roster(entry(2, 10, "9"), entry(3, 10, "5")),
+		want:     []change{{sdk.OperationCreate, 2, "joined"}, {sdk.OperationUpdate, 3, "team"}, {sdk.OperationDelete, 1, "left"}},
+	}}
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			records := diffRosters(tc.previous, tc.current, now)
+			is.Equal(len(records), len(tc.want))
+			for i, rec := range records {
+				is.Equal(rec.Operation, tc.want[i].op)
+				is.Equal(rec.Key, rosterKey(tc.want[i].playerID))
+				is.Equal(rec.Metadata[metadataRosterChange], tc.want[i].change)
+
+				// all records but the last resume from the previous rosters
+				wantHash := ""
+				if tc.previous != nil {
+					wantHash = rosterHash(tc.previous)
+				}
+				if i == len(records)-1 {
+					wantHash = rosterHash(tc.current)
+				}
+				is.Equal(rec.Position, rosterPosition(now, tc.want[i].playerID, wantHash))
+				is.Equal(rosterPositionHash(rec.Position), wantHash)
+			}
+		})
+	}
+}
+
+func TestRosterTracker_Resume(t *testing.T) {
+	// rosters maps team IDs to the jersey numbers of their players, keyed by
+	// player ID
+	rosters := map[int]map[int]string{
+		10: {1: "2", 2: "9"},
+		11: {3: "5"},
+	}
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		switch strings.TrimPrefix(r.URL.Path, "/stats/") {
+		case "commonteamyears":
+			_, _ = w.Write(statsResponse("TeamYears", []string{"TEAM_ID", "MIN_YEAR", "MAX_YEAR"},
+				[]interface{}{10.0, "1946", "2024"}, []interface{}{11.0, "1946", "2024"}))
+		case "commonteamroster":
+			teamID, _ := strconv.Atoi(r.URL.Query().Get("TeamID"))
+			var rows [][]interface{}
+			for playerID, number := range rosters[teamID] {
+				rows = append(rows, []interface{}{float64(playerID), number, "G"})
+			}
+			_, _ = w.Write(statsResponse("CommonTeamRoster", []string{"PLAYER_ID", "NUM", "POSITION"}, rows...))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	params := NBAStatsQueryParams{LeagueID: "00", Season: "2023-24"}
+	ctx := context.Background()
+
+	is := is.New(t)
+	tracker := newRosterTracker(client, params, nil)
+	records, err := tracker.poll(ctx)
+	is.NoErr(err)
+	is.Equal(len(records), 3)
+	last := records[len(records)-1].Position
+
+	// the rosters didn't change since the last acknowledged record
+	tracker = newRosterTracker(client, params, last)
+	records, err = tracker.poll(ctx)
+	is.NoErr(err)
+	is.Equal(len(records), 0)
+
+	// the rosters changed since the last acknowledged record
+	rosters[11][3] = "8"
+	tracker = newRosterTracker(client, params, last)
+	records, err = tracker.poll(ctx)
+	is.NoErr(err)
+	is.Equal(len(records), 3)
+	is.Equal(records[0].Operation, sdk.OperationSnapshot)
+
+	// the changes since the resumed position are emitted on the next poll
+	rosters[10][1] = "7"
+	records, err = tracker.poll(ctx)
+	is.NoErr(err)
+	is.Equal(len(records), 1)
+	is.Equal(records[0].Operation, sdk.OperationUpdate)
+	is.Equal(records[0].Metadata[metadataRosterChange], "jersey")
+}
@@ -0,0 +1,147 @@
+package nbastats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// snapshotFile is a file a snapshot is written to. It replaces the file of
+// the previous snapshot only once it is complete.
+type snapshotFile interface {
+	// write writes the row of the record to the snapshot.
+	write(rec sdk.Record) error
+	// complete atomically replaces the previous snapshot.
+	complete() error
+	// discard removes the incomplete snapshot, leaving the previous snapshot
+	// in place.
+	discard() error
+}
+
+// snapshotWriter writes every snapshot of rows emitted by the source to a
+// file that replaces the file of the previous snapshot with the same name,
+// so that readers always see a whole snapshot.
+type snapshotWriter struct {
+	names nameTemplate
+	// create creates the file of the snapshot with the given name, rec is
+	// its first record.
+	create    func(rec sdk.Record, name string) (snapshotFile, error)
+	snapshots map[string]*pendingSnapshot
+}
+
+// pendingSnapshot is a snapshot that isn't complete yet. file is nil for
+// snapshots that are skipped.
+type pendingSnapshot struct {
+	id   string
+	file snapshotFile
+}
+
+func newSnapshotWriter(names nameTemplate, create func(rec sdk.Record, name string) (snapshotFile, error)) *snapshotWriter {
+	return &snapshotWriter{names: names, create: create, snapshots: make(map[string]*pendingSnapshot)}
+}
+
+// write writes the records to the files of their snapshots. Records are
+// acknowledged once written, also if their snapshot isn't complete yet. A
+// snapshot that is discarded because the destination stopped is therefore
+// not delivered again: the source emits whole snapshots on every poll,
+// which replace the previous snapshot instead.
+func (w *snapshotWriter) write(ctx context.Context, records []sdk.Record) (int, error) {
+	for i, rec := range records {
+		name, err := w.names.fileName(rec, time.Now(), 0)
+		if err != nil {
+			return i, err
+		}
+		s, err := w.snapshot(ctx, rec, name)
+		if err != nil {
+			return i, fmt.Errorf("invalid record %d: %w", i, err)
+		}
+		complete := rec.Metadata[metadataSnapshotComplete] == "true"
+		if s.file == nil {
+			if complete {
+				delete(w.snapshots, name)
+			}
+			continue
+		}
+		err = s.file.write(rec)
+		if err != nil {
+			return i, fmt.Errorf("failed to write record %d to snapshot %q: %w", i, name, err)
+		}
+		if complete {
+			delete(w.snapshots, name)
+			err = s.file.complete()
+			if err != nil {
+				return i, err
+			}
+			sdk.Logger(ctx).Debug().Str("snapshot", name).Msg("replaced snapshot")
+		}
+	}
+	return len(records), nil
+}
+
+// snapshot returns the pending snapshot of the record, starting a new one
+// if the record is the first row of a snapshot.
+func (w *snapshotWriter) snapshot(ctx context.Context, rec sdk.Record, name string) (*pendingSnapshot, error) {
+	id, err := snapshotID(rec)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := w.snapshots[name]
+	if ok && s.id == id {
+		return s, nil
+	}
+	if ok && s.file != nil {
+		sdk.Logger(ctx).Warn().Str("snapshot", name).Msg("discarding incomplete snapshot")
+		err := s.file.discard()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s = &pendingSnapshot{id: id}
+	if rec.Metadata[metadataSnapshotRow] == "0" {
+		s.file, err = w.create(rec, name)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// the snapshot started before the connector did, e.g. when the
+		// source resumed in the middle of an archived response
+		sdk.Logger(ctx).Warn().Str("snapshot", name).Msg("skipping snapshot that started before the destination")
+	}
+	w.snapshots[name] = s
+	return s, nil
+}
+
+// close discards all incomplete snapshots.
+func (w *snapshotWriter) close() error {
+	var errs []error
+	for name, s := range w.snapshots {
+		if s.file != nil {
+			errs = append(errs, s.file.discard())
+		}
+		delete(w.snapshots, name)
+	}
+	return errors.Join(errs...)
+}
+
+// snapshotID returns the snapshot the record belongs to.
+func snapshotID(rec sdk.Record) (string, error) {
+	id := rec.Metadata[metadataSnapshot]
+	if id == "" {
+		return "", errors.New("record is not part of a snapshot, write mode snapshot requires the rows emitted by the source with format rows")
+	}
+	return id, nil
+}
+
+// discardFile closes and removes an incomplete file.
+func discardFile(f *os.File) error {
+	err := f.Close()
+	if removeErr := os.Remove(f.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
@@ -4,27 +4,143 @@ package nbastats
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"time"
 
 	sdk "github.com/conduitio/conduit-connector-sdk"
 	"golang.org/x/time/rate"
 )
 
+const (
+	// metadataSeason is the record metadata key containing the season the
+	// record belongs to.
+	metadataSeason = "nbastats.season"
+	// metadataPreviousSeason is the record metadata key marking the records
+	// emitted right after the season rolled over, it contains the season that
+	// ended.
+	metadataPreviousSeason = "nbastats.season.previous"
+	// metadataLeague is the record metadata key containing the league (nba,
+	// wnba or gleague) the record belongs to.
+	metadataLeague = "nbastats.league"
+	// metadataSeasonType is the record metadata key containing the season
+	// type, e.g. "Regular Season" or "Playoffs".
+	metadataSeasonType = "nbastats.season_type"
+	// metadataMeasureType is the record metadata key containing the measure
+	// type of tracking stats, e.g. "SpeedDistance".
+	metadataMeasureType = "nbastats.measure_type"
+	// metadataDate is the record metadata key containing the date (formatted
+	// as YYYY-MM-DD) the stats in the record were fetched.
+	metadataDate = "nbastats.date"
+)
+
 type Source struct {
 	sdk.UnimplementedSource
 
 	config                  SourceConfig
+	params                  NBAStatsQueryParams
+	client                  *statsClient
 	lastPositionRead        sdk.Position //nolint:unused // this is just an example
 	limiter                 *rate.Limiter
 	cachedSpeedDistanceData []byte
+	dimensions              *dimensionCache
+	roster                  *rosterTracker
+	windows                 []statsWindow
+	archive                 *archiveReader
+	// buffer holds records fetched by the last poll that weren't read yet.
+	buffer []sdk.Record
+	// pending holds the windows of the last poll that weren't streamed yet
+	// and stream is the response of streamed, currently read row by row
+	// (format rows). resume is the number of rows of the first pending
+	// window that were already read before its stream failed.
+	pending  []statsWindow
+	streamed statsWindow
+	stream   *rowStream
+	resume   int
+	// pollMetadata is added to every record of the last poll.
+	pollMetadata sdk.Metadata
+	timestamp    string
 }
 
 type SourceConfig struct {
 	// Config includes parameters that are the same in the source and destination.
 	Config
-	// SourceConfigParam is named foo and must be provided by the user.
-	SourceConfigParam string `json:"foo" validate:"required"`
+	// Mode selects what the source emits: "stats" emits the tracking stats
+	// on every poll, "roster" emits a CDC record whenever a player joins or
+	// leaves a team roster or changes jersey number or position, "archive"
+	// emits the stats of archived responses read from files.
+	Mode string `json:"mode" validate:"inclusion=stats|roster|archive" default:"stats"`
+	// League is the league to query, one of nba, wnba or gleague.
+	League string `json:"league" validate:"inclusion=nba|wnba|gleague" default:"nba"`
+	// Season to query, formatted as YYYY-YY for the NBA and G League and as
+	// YYYY for the WNBA. The value "current" follows the league calendar and
+	// rolls over to the next season while the pipeline is running.
+	Season string `json:"season" default:"current"`
+	// Windows is a list of windows the stats are aggregated over on every
+	// poll: "season" for the whole season, "last<N>" for the last N games or
+	// "last<N>d" for the last N days.
+	Windows []string `json:"windows" default:"season"`
+	// Format selects how stats are emitted: "response" emits the whole
+	// response of every request as one raw record, "rows" decodes the
+	// response incrementally and emits a structured record for every row.
+	Format string `json:"format" validate:"inclusion=response|rows" default:"response"`
+	// Enrichment configures the player and team columns joined onto rows.
+	Enrichment EnrichmentConfig `json:"enrichment"`
+	// HTTP configures the connection to stats.nba.com.
+	HTTP HTTPConfig `json:"http"`
+	// Archive configures the files read in archive mode.
+	Archive ArchiveConfig `json:"archive"`
+}
+
+// queryParams validates the league specific settings and returns the query
+// parameters used for every request.
+func (c SourceConfig) queryParams() (NBAStatsQueryParams, error) {
+	params := NewNBAStatsQueryParams()
+	l, ok := leagues[c.League]
+	if !ok {
+		return params, fmt.Errorf("unsupported league %q", c.League)
+	}
+
+	season := c.Season
+	if c.followsCurrentSeason() {
+		season = l.currentSeason(time.Now())
+	}
+	err := l.validateSeason(season)
+	if err != nil {
+		return params, err
+	}
+	err = l.validateEndpoints(c.endpoints())
+	if err != nil {
+		return params, fmt.Errorf("%s mode is not supported: %w", c.Mode, err)
+	}
+
+	params.LeagueID = l.ID
+	params.Season = season
+	params.PerMode = c.PerMode
+	return params, nil
+}
+
+// followsCurrentSeason returns true if the season should be resolved from the
+// league calendar.
+func (c SourceConfig) followsCurrentSeason() bool {
+	return c.Season == "" || c.Season == "current"
+}
+
+// endpoints returns the stats.nba.com endpoints queried with this config.
+func (c SourceConfig) endpoints() []string {
+	var endpoints []string
+	switch c.Mode {
+	case "roster":
+		endpoints = append(endpoints, "commonteamyears", "commonteamroster")
+	case "archive":
+	default:
+		endpoints = append(endpoints, "leaguedashptstats")
+	}
+	if c.Enrichment.Enabled {
+		endpoints = append(endpoints, "commonallplayers", "commonplayerinfo", "teaminfocommon")
+	}
+	return endpoints
 }
 
 func NewSource() sdk.Source {
@@ -53,6 +169,40 @@ func (s *Source) Configure(ctx context.Context, cfg map[string]string) error {
 	if err != nil {
 		return fmt.Errorf("invalid config: %w", err)
 	}
+	s.params, err = s.config.queryParams()
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	s.windows, err = parseWindows(s.config.Windows)
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	_, err = s.config.HTTP.requestHeaders()
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	_, err = s.config.HTTP.Proxy.proxyFunc()
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	_, err = s.config.HTTP.Cache.endpointTTLs()
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	err = s.config.HTTP.Cassette.validate()
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	err = s.config.Enrichment.validate()
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	if s.config.Mode == "archive" {
+		err = s.config.Archive.validate()
+		if err != nil {
+			return fmt.Errorf("invalid config: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -64,6 +214,28 @@ func (s *Source) Open(ctx context.Context, pos sdk.Position) error {
 	// start producing records after this position. The context passed to Open
 	// will be cancelled once the plugin receives a stop signal from Conduit.
 	s.limiter = rate.NewLimiter(rate.Every(s.config.PollingPeriod), 1)
+	var err error
+	season := s.config.Season
+	if s.config.followsCurrentSeason() {
+		season = "current"
+	}
+	s.client, err = newStatsClient(s.config.HTTP, season)
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+	if s.config.Mode == "roster" {
+		s.roster = newRosterTracker(s.client, s.params, pos)
+	}
+	if s.config.Enrichment.Enabled {
+		s.dimensions = newDimensionCache(s.client, s.config.Enrichment, s.params)
+		s.dimensions.start(ctx)
+	}
+	if s.config.Mode == "archive" {
+		s.archive, err = newArchiveReader(s.config.Archive, s.config.Format, s.dimensions, pos)
+		if err != nil {
+			return fmt.Errorf("failed to open archive: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -82,19 +254,90 @@ func (s *Source) Read(ctx context.Context) (sdk.Record, error) {
 	// After Read returns an error the function won't be called again (except if
 	// the error is ErrBackoffRetry, as mentioned above).
 	// Read can be called concurrently with Ack.
-	err := s.limiter.Wait(ctx)
-	if err != nil {
-		return sdk.Record{}, err
-	} else {
-		sdk.Logger(ctx).Info().Msgf("Waiting for %s before next request for data", s.config.PollingPeriod)
+	if s.archive != nil {
+		return s.readArchive(ctx)
 	}
-	rec, err := s.getRecord(ctx)
-	if err != nil {
-		return sdk.Record{}, fmt.Errorf("error getting the weather data: %w", err)
+	for len(s.buffer) == 0 {
+		if s.stream != nil {
+			rec, err := s.stream.next(ctx)
+			if err == nil {
+				s.tag(rec)
+				return rec, nil
+			}
+			read := s.stream.returned()
+			s.closeStream(ctx)
+			if !errors.Is(err, io.EOF) {
+				if isRetryable(err) {
+					// stream the rest of the window after the backoff
+					s.pending = append([]statsWindow{s.streamed}, s.pending...)
+					s.resume = read
+				}
+				return sdk.Record{}, s.readError(ctx, err)
+			}
+			continue
+		}
+		if len(s.pending) > 0 {
+			window := s.pending[0]
+			s.pending = s.pending[1:]
+			var err error
+			s.stream, err = s.openStream(ctx, window, s.resume)
+			if err != nil {
+				if isRetryable(err) {
+					// open the same window again after the backoff
+					s.pending = append([]statsWindow{window}, s.pending...)
+				}
+				return sdk.Record{}, s.readError(ctx, err)
+			}
+			s.streamed, s.resume = window, 0
+			continue
+		}
+
+		err := s.limiter.Wait(ctx)
+		if err != nil {
+			return sdk.Record{}, err
+		} else {
+			sdk.Logger(ctx).Info().Msgf("Waiting for %s before next request for data", s.config.PollingPeriod)
+		}
+		s.buffer, err = s.poll(ctx)
+		if err != nil {
+			return sdk.Record{}, s.readError(ctx, err)
+		}
+		if len(s.buffer) == 0 && len(s.pending) == 0 {
+			return sdk.Record{}, sdk.ErrBackoffRetry
+		}
 	}
+	rec := s.buffer[0]
+	s.buffer = s.buffer[1:]
 	return rec, nil
 }
 
+// readArchive returns the next record read from the archived responses.
+// Once all files were read, the archive is scanned for new files every
+// polling period.
+func (s *Source) readArchive(ctx context.Context) (sdk.Record, error) {
+	for {
+		rec, err := s.archive.next(ctx)
+		if err == nil {
+			return rec, nil
+		}
+		if !errors.Is(err, io.EOF) {
+			return sdk.Record{}, s.readError(ctx, err)
+		}
+
+		err = s.limiter.Wait(ctx)
+		if err != nil {
+			return sdk.Record{}, err
+		}
+		n, err := s.archive.scan()
+		if err != nil {
+			return sdk.Record{}, fmt.Errorf("failed to scan archive: %w", err)
+		}
+		if n == 0 {
+			return sdk.Record{}, sdk.ErrBackoffRetry
+		}
+	}
+}
+
 func (s *Source) Ack(ctx context.Context, position sdk.Position) error {
 	// Ack signals to the implementation that the record with the supplied
 	// position was successfully processed. This method might be called after
@@ -110,16 +353,137 @@ func (s *Source) Teardown(ctx context.Context) error {
 	// Teardown signals to the plugin that there will be no more calls to any
 	// other function. After Teardown returns, the plugin should be ready for a
 	// graceful shutdown.
+	s.closeStream(ctx)
+	if s.archive != nil {
+		s.archive.closeStream(ctx)
+	}
+	if s.dimensions != nil {
+		s.dimensions.close()
+	}
+	if s.client != nil {
+		s.client.close()
+	}
 	return nil
 }
 
-func (s *Source) getRecord(ctx context.Context) (sdk.Record, error) {
-	speedDistanceData, err := fetchNBASpeedDistanceStats(s.config.PerMode)
+// poll fetches the records produced by the configured mode and tags them
+// with the season they belong to. With format rows, poll only schedules the
+// windows to be streamed by Read and returns no records.
+func (s *Source) poll(ctx context.Context) ([]sdk.Record, error) {
+	previousSeason := s.rollover(ctx)
+	s.pollMetadata = sdk.Metadata{
+		metadataSeason:     s.params.Season,
+		metadataLeague:     s.config.League,
+		metadataSeasonType: s.params.SeasonType,
+		metadataDate:       time.Now().Format("2006-01-02"),
+	}
+	if s.roster == nil {
+		s.pollMetadata[metadataMeasureType] = s.params.PtMeasureType
+	}
+	if previousSeason != "" {
+		s.pollMetadata[metadataPreviousSeason] = previousSeason
+	}
+
+	var records []sdk.Record
+	switch {
+	case s.roster != nil:
+		var err error
+		records, err = s.roster.poll(ctx)
+		if err != nil {
+			return nil, err
+		}
+	case s.config.Format == formatRows:
+		s.pending = append(s.pending[:0], s.windows...)
+		// the timestamp identifies the snapshots of the poll, so it has to
+		// differ between polls even with short polling periods
+		s.timestamp = time.Now().UTC().Format(snapshotTimestampFormat)
+	default:
+		for _, window := range s.windows {
+			rec, err := s.getRecord(ctx, window)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, rec)
+		}
+	}
+
+	for _, rec := range records {
+		s.tag(rec)
+	}
+	return records, nil
+}
+
+// tag adds the metadata of the current poll to the record.
+func (s *Source) tag(rec sdk.Record) {
+	for k, v := range s.pollMetadata {
+		rec.Metadata[k] = v
+	}
+}
+
+// openStream requests the stats of the given window and returns a stream
+// building a record for every row of the response, starting after the
+// given number of rows.
+func (s *Source) openStream(ctx context.Context, window statsWindow, skip int) (*rowStream, error) {
+	url := buildNBAStatsURL(window.apply(s.params, time.Now()))
+	body, err := s.client.openNBAStats(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return newRowStream(url, body, s.dimensions, window.Name, s.config.PerMode, s.timestamp, skip), nil
+}
+
+// closeStream closes the response currently streamed, if any.
+func (s *Source) closeStream(ctx context.Context) {
+	if s.stream == nil {
+		return
+	}
+	err := s.stream.close()
+	if err != nil {
+		sdk.Logger(ctx).Warn().Err(err).Msg("failed to close response")
+	}
+	s.stream = nil
+}
+
+// rollover switches to the next season once it starts, if the source
+// follows the current season. It returns the season that ended, or an empty
+// string if the season didn't change.
+func (s *Source) rollover(ctx context.Context) string {
+	if !s.config.followsCurrentSeason() {
+		return ""
+	}
+	season := leagues[s.config.League].currentSeason(time.Now())
+	if season == s.params.Season {
+		return ""
+	}
+
+	previous := s.params.Season
+	s.params.Season = season
+	if s.roster != nil {
+		s.roster.params.Season = season
+	}
+	if s.dimensions != nil {
+		s.dimensions.rollover(season)
+	}
+	sdk.Logger(ctx).Info().
+		Str("previous", previous).
+		Str("season", season).
+		Msg("season rolled over")
+	return previous
+}
+
+func (s *Source) getRecord(ctx context.Context, window statsWindow) (sdk.Record, error) {
+	speedDistanceData, err := s.client.fetchNBASpeedDistanceStats(ctx, window.apply(s.params, time.Now()))
 	if err != nil {
 		return sdk.Record{}, err
 	}
 
 	sdk.Logger(ctx).Info().Msg("Successfully fetched the NBA Speed and Distance data...")
+	if s.dimensions != nil {
+		speedDistanceData, err = s.dimensions.enrichBody(ctx, speedDistanceData)
+		if err != nil {
+			return sdk.Record{}, err
+		}
+	}
 	// if s.cachedSpeedDistanceData == nil || bytes.Equal(speedDistanceData, s.cachedSpeedDistanceData) == false {
 	// 	s.cachedSpeedDistanceData = speedDistanceData
 	// 	sdk.Logger(ctx).Info().Msg("Successfully fetched the NBA Speed and Distance data...")
@@ -134,13 +498,24 @@ func (s *Source) getRecord(ctx context.Context) (sdk.Record, error) {
 	timestampStr := currentTime.Format("2006-01-02-1504")
 
 	// Create the final string using the pattern with the formatted timestamp
-	key := fmt.Sprintf("%s_%s", timestampStr, s.config.PerMode)
+	key := fmt.Sprintf("%s_%s_%s", timestampStr, s.config.PerMode, window.Name)
 	recordKey := sdk.RawData(key)
 	recordValue := sdk.RawData(speedDistanceData)
 	return sdk.Util.Source.NewRecordCreate(
 		sdk.Position(recordKey),
-		nil,
+		sdk.Metadata{metadataWindow: window.Name},
 		recordKey,
 		recordValue,
 	), nil
 }
+
+// readError returns the error Read fails with. Transient errors are logged
+// and turned into sdk.ErrBackoffRetry, so that Read is retried after a
+// backoff instead of stopping the pipeline.
+func (s *Source) readError(ctx context.Context, err error) error {
+	if isRetryable(err) {
+		sdk.Logger(ctx).Warn().Err(err).Msg("failed to read NBA stats, retrying after backoff")
+		return sdk.ErrBackoffRetry
+	}
+	return fmt.Errorf("failed to read NBA stats: %w", err)
+}
@@ -0,0 +1,163 @@
+package nbastats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/matryer/is"
+	"golang.org/x/time/rate"
+)
+
+// errReader fails every read with err.
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+
+// newRowsSource returns a source streaming the given windows with format
+// rows from client.
+func newRowsSource(client *statsClient, windows ...string) *Source {
+	parsed, _ := parseWindows(windows)
+	return &Source{
+		config:  SourceConfig{Config: Config{PerMode: "PerGame"}, Format: formatRows},
+		params:  NewNBAStatsQueryParams(),
+		client:  client,
+		limiter: rate.NewLimiter(rate.Inf, 1),
+		windows: parsed,
+	}
+}
+
+func TestSourceRead_ResumeStream(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	body := string(statsResponse("A", []string{"PLAYER_ID"}, []interface{}{1}, []interface{}{2}, []interface{}{3}, []interface{}{4}, []interface{}{5}))
+
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	client := &statsClient{client: &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			window := req.URL.Query().Get("DateFrom")
+			requests[window]++
+			var r io.Reader = strings.NewReader(body)
+			if window == "" && requests[window] == 1 {
+				// the first response of the season window times out after
+				// three rows
+				r = io.MultiReader(strings.NewReader(body[:strings.Index(body, "[4]")]), errReader{os.ErrDeadlineExceeded})
+			}
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(r)}, nil
+		}),
+	}}
+	s := newRowsSource(client, "season", "last7d")
+
+	var got []sdk.Record
+	retries := 0
+	for len(got) < 10 {
+		rec, err := s.Read(ctx)
+		if err == sdk.ErrBackoffRetry {
+			retries++
+			continue
+		}
+		is.NoErr(err)
+		got = append(got, rec)
+	}
+	is.Equal(retries, 1)
+	is.Equal(len(requests), 2)
+
+	for i, rec := range got {
+		row := i % 5
+		is.Equal(rec.Key, sdk.StructuredData{"PLAYER_ID": row + 1})
+		is.True(strings.HasSuffix(string(rec.Position), "_"+strconv.Itoa(row)))
+		is.Equal(rec.Metadata[metadataSnapshotRow], strconv.Itoa(row))
+		_, complete := rec.Metadata[metadataSnapshotComplete]
+		is.Equal(complete, row == 4)
+	}
+	// the resumed rows belong to the snapshot of the failed stream
+	is.Equal(got[0].Metadata[metadataSnapshot], got[4].Metadata[metadataSnapshot])
+	is.Equal(got[5].Metadata[metadataWindow], "last7d")
+}
+
+func TestSourceRead_Abort(t *testing.T) {
+	testCases := []struct {
+		name string
+		// headers makes the server send the headers and the first rows before
+		// blocking, so that the response is being streamed when it's aborted.
+		// Read peeks at the row after the one it returns, the second row
+		// lets it return the first one.
+		headers bool
+		// teardown aborts by tearing the source down after Read returned the
+		// first row instead of cancelling the context of Read.
+		teardown bool
+	}{
+		{name: "cancelled request"},
+		{name: "cancelled stream", headers: true},
+		{name: "teardown stream", headers: true, teardown: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			aborted := make(chan struct{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.headers {
+					fmt.Fprint(w, `{"resultSets":[{"name":"A","headers":["PLAYER_ID"],"rowSet":[[1],[2],`)
+					w.(http.Flusher).Flush()
+				}
+				select {
+				case <-r.Context().Done():
+					close(aborted)
+				case <-time.After(10 * time.Second):
+				}
+			}))
+			defer srv.Close()
+			s := newRowsSource(newServerClient(t, srv, HTTPConfig{}), "season")
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.headers {
+				rec, err := s.Read(ctx)
+				is.NoErr(err)
+				is.Equal(rec.Key, sdk.StructuredData{"PLAYER_ID": 1})
+			}
+
+			if tc.teardown {
+				is.NoErr(s.Teardown(context.Background()))
+			} else {
+				errs := make(chan error)
+				go func() {
+					// rows already decoded are still returned after the
+					// context is cancelled
+					for {
+						if _, err := s.Read(ctx); err != nil {
+							errs <- err
+							return
+						}
+					}
+				}()
+				time.Sleep(50 * time.Millisecond)
+				cancel()
+				select {
+				case err := <-errs:
+					is.True(errors.Is(err, context.Canceled))
+				case <-time.After(5 * time.Second):
+					t.Fatal("Read wasn't aborted")
+				}
+			}
+			select {
+			case <-aborted:
+			case <-time.After(5 * time.Second):
+				t.Fatal("request wasn't aborted")
+			}
+		})
+	}
+}
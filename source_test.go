@@ -2,9 +2,13 @@ package nbastats_test
 
 import (
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
 	nbastats "github.com/William-Hill/conduit-connector-nba-stats"
+	sdk "github.com/conduitio/conduit-connector-sdk"
 	"github.com/matryer/is"
 )
 
@@ -14,3 +18,143 @@ func TestTeardownSource_NoOpen(t *testing.T) {
 	err := con.Teardown(context.Background())
 	is.NoErr(err)
 }
+
+func TestConfigureSource(t *testing.T) {
+	testCases := []struct {
+		name    string
+		cfg     map[string]string
+		wantErr bool
+	}{
+		{
+			name: "nba default season",
+			cfg:  map[string]string{"per_mode": "PerGame", "mode": "stats", "league": "nba"},
+		},
+		{
+			name: "wnba single year season",
+			cfg:  map[string]string{"per_mode": "PerGame", "mode": "stats", "league": "wnba", "season": "2023"},
+		},
+		{
+			name: "wnba current season",
+			cfg:  map[string]string{"per_mode": "PerGame", "mode": "stats", "league": "wnba", "season": "current"},
+		},
+		{
+			name:    "wnba multi year season",
+			cfg:     map[string]string{"per_mode": "PerGame", "mode": "stats", "league": "wnba", "season": "2023-24"},
+			wantErr: true,
+		},
+		{
+			name:    "gleague tracking stats",
+			cfg:     map[string]string{"per_mode": "PerGame", "mode": "stats", "league": "gleague"},
+			wantErr: true,
+		},
+		{
+			name: "gleague rosters",
+			cfg:  map[string]string{"per_mode": "PerGame", "mode": "roster", "league": "gleague"},
+		},
+		{
+			name: "rolling windows",
+			cfg:  map[string]string{"per_mode": "PerGame", "mode": "stats", "league": "nba", "windows": "season,last5,last10,last7d,last30d"},
+		},
+		{
+			name:    "invalid window",
+			cfg:     map[string]string{"per_mode": "PerGame", "mode": "stats", "league": "nba", "windows": "last5games"},
+			wantErr: true,
+		},
+		{
+			name: "custom headers",
+			cfg:  map[string]string{"per_mode": "PerGame", "mode": "stats", "league": "nba", "http.headerProfile": "browser", "http.headers": "x-nba-stats-token: true\nOrigin: https://stats.nba.com"},
+		},
+		{
+			name:    "invalid header",
+			cfg:     map[string]string{"per_mode": "PerGame", "mode": "stats", "league": "nba", "http.headers": "x-nba-stats-token"},
+			wantErr: true,
+		},
+		{
+			name: "socks5 proxy",
+			cfg:  map[string]string{"per_mode": "PerGame", "mode": "stats", "league": "nba", "http.proxy.url": "socks5://proxy.example.com:1080", "http.proxy.username": "user", "http.proxy.password": "secret"},
+		},
+		{
+			name:    "unsupported proxy scheme",
+			cfg:     map[string]string{"per_mode": "PerGame", "mode": "stats", "league": "nba", "http.proxy.url": "ftp://proxy.example.com"},
+			wantErr: true,
+		},
+		{
+			name:    "replay without cassette dir",
+			cfg:     map[string]string{"per_mode": "PerGame", "mode": "stats", "league": "nba", "http.cassette.mode": "replay"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			con := nbastats.NewSource()
+			err := con.Configure(context.Background(), tc.cfg)
+			is.Equal(err != nil, tc.wantErr)
+		})
+	}
+}
+
+func TestReadSource_Archive(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	files := map[string]string{
+		"speed-2024-01-02.json": `{"resource":"leaguedashptstats","parameters":{"Season":"2023-24","PerMode":"PerGame","LastNGames":0},` +
+			`"resultSets":[{"name":"LeagueDashPtStats","headers":["PLAYER_ID","DIST_MILES"],"rowSet":[[1,2.5],[2,2.1]]}]}`,
+		"speed-2024-01-01.json": `{"resource":"leaguedashptstats","parameters":{"Season":"2023-24","PerMode":"PerGame","LastNGames":5},` +
+			`"resultSets":[{"name":"LeagueDashPtStats","headers":["PLAYER_ID","DIST_MILES"],"rowSet":[[3,1.9]]}]}`,
+		"speed-2024-01-03.json": `{"resource":"leaguedashptstats","parameters":{"Season":"2023-24","PerMode":"PerGame","LastNGames":0,"DateFrom":"12/28/2023","DateTo":"01/03/2024"},` +
+			`"resultSets":[{"name":"LeagueDashPtStats","headers":["PLAYER_ID","DIST_MILES"],"rowSet":[[4,2.7]]}]}`,
+	}
+	for name, body := range files {
+		is.NoErr(os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644))
+	}
+	cfg := map[string]string{
+		"per_mode":      "PerGame",
+		"mode":          "archive",
+		"league":        "nba",
+		"format":        "rows",
+		"archive.path":  dir,
+		"archive.order": "date",
+	}
+
+	read := func(pos sdk.Position, n int) []sdk.Record {
+		con := nbastats.NewSource()
+		is.NoErr(con.Configure(ctx, cfg))
+		is.NoErr(con.Open(ctx, pos))
+		defer func() { is.NoErr(con.Teardown(ctx)) }()
+		var recs []sdk.Record
+		for len(recs) < n {
+			rec, err := con.Read(ctx)
+			if errors.Is(err, sdk.ErrBackoffRetry) {
+				break
+			}
+			is.NoErr(err)
+			recs = append(recs, rec)
+		}
+		return recs
+	}
+
+	recs := read(nil, 4)
+	is.Equal(len(recs), 4)
+	is.Equal(recs[0].Metadata["opencdc.collection"], "LeagueDashPtStats_last5")
+	is.Equal(recs[0].Metadata["nbastats.window"], "last5")
+	is.Equal(recs[0].Metadata["nbastats.season"], "2023-24")
+	is.Equal(recs[1].Metadata["opencdc.collection"], "LeagueDashPtStats")
+	is.Equal(recs[1].Metadata["nbastats.window"], "season")
+	is.Equal(recs[3].Metadata["opencdc.collection"], "LeagueDashPtStats_last7d")
+	is.Equal(recs[3].Metadata["nbastats.window"], "last7d")
+	is.Equal(recs[1].Key, sdk.StructuredData{"PLAYER_ID": 1})
+	is.Equal(recs[2].Payload.After.(sdk.StructuredData)["DIST_MILES"], 2.1)
+	// every file is a snapshot of its rows
+	is.Equal(recs[1].Metadata["nbastats.snapshot"], recs[2].Metadata["nbastats.snapshot"])
+	is.Equal(recs[1].Metadata["nbastats.snapshot.complete"], "")
+	is.Equal(recs[2].Metadata["nbastats.snapshot.row"], "1")
+	is.Equal(recs[2].Metadata["nbastats.snapshot.complete"], "true")
+
+	// resuming after the second record continues with the remaining rows
+	resumed := read(recs[1].Position, 3)
+	is.Equal(len(resumed), 2)
+	is.Equal(resumed[0].Key, sdk.StructuredData{"PLAYER_ID": 2})
+	is.Equal(resumed[1].Key, sdk.StructuredData{"PLAYER_ID": 4})
+}
@@ -0,0 +1,429 @@
+package nbastats
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	_ "modernc.org/sqlite" // registers the sqlite driver
+)
+
+// sqliteRawKey is the primary key column of tables whose records have a raw
+// key.
+const sqliteRawKey = "_key"
+
+// sqliteStagingSuffix is appended to the name of a table to get the name of
+// the table its next snapshot is written to.
+const sqliteStagingSuffix = "_staging"
+
+// sqliteWriter writes records to a SQLite database, one table per name
+// returned by the name template, the collection by default. Records are
+// upserted on their key, delete records delete the row with their key.
+//
+// In snapshot mode, the rows of a snapshot are written to a staging table
+// that replaces the table in the transaction writing the last row of the
+// snapshot.
+type sqliteWriter struct {
+	db     *sql.DB
+	names  nameTemplate
+	tables map[string]*sqliteTable
+	// snapshots are the incomplete snapshots by table name, nil if the
+	// writer isn't in snapshot mode.
+	snapshots map[string]sqliteSnapshot
+}
+
+// sqliteSnapshot is an incomplete snapshot. Snapshots that started before
+// the writer are skipped.
+type sqliteSnapshot struct {
+	id   string
+	skip bool
+}
+
+// sqliteTable is the schema of a table as known to the writer.
+type sqliteTable struct {
+	name    string
+	key     []string
+	columns map[string]bool
+}
+
+func newSQLiteWriter(path string, names nameTemplate, snapshot bool) (*sqliteWriter, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database dir: %w", err)
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// a single connection serializes all writes, SQLite only supports one
+	// writer at a time anyway
+	db.SetMaxOpenConns(1)
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	w := &sqliteWriter{db: db, names: names, tables: make(map[string]*sqliteTable)}
+	if snapshot {
+		w.snapshots = make(map[string]sqliteSnapshot)
+	}
+	return w, nil
+}
+
+// write writes the records in a single transaction. If a record fails, the
+// records before it are committed.
+func (w *sqliteWriter) write(ctx context.Context, records []sdk.Record) (int, error) {
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	for i, rec := range records {
+		err = w.writeRecord(ctx, tx, rec)
+		if err != nil {
+			err = fmt.Errorf("failed to write record %d: %w", i, err)
+			if commitErr := tx.Commit(); commitErr != nil {
+				w.reset()
+				return 0, errors.Join(err, commitErr)
+			}
+			return i, err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		w.reset()
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(records), nil
+}
+
+// reset forgets the state of the database after a rollback. The table
+// schemas may be out of date and the rows of incomplete snapshots lost, the
+// rest of those snapshots is skipped.
+func (w *sqliteWriter) reset() {
+	w.tables = make(map[string]*sqliteTable)
+	if w.snapshots != nil {
+		w.snapshots = make(map[string]sqliteSnapshot)
+	}
+}
+
+func (w *sqliteWriter) writeRecord(ctx context.Context, tx *sql.Tx, rec sdk.Record) error {
+	key, err := sqliteKey(rec.Key)
+	if err != nil {
+		return err
+	}
+	name, err := w.names.name(rec, time.Now(), 0)
+	if err != nil {
+		return err
+	}
+	if w.snapshots != nil {
+		return w.writeSnapshotRecord(ctx, tx, rec, name, key)
+	}
+	if rec.Operation == sdk.OperationDelete {
+		return w.delete(ctx, tx, name, key)
+	}
+
+	return w.upsert(ctx, tx, rec, name, key)
+}
+
+// upsert upserts the row of the record into the table with the given name.
+func (w *sqliteWriter) upsert(ctx context.Context, tx *sql.Tx, rec sdk.Record, tableName string, key sdk.StructuredData) error {
+	fields, err := recordFields(rec)
+	if err != nil {
+		return err
+	}
+	if fields == nil {
+		return nil
+	}
+	row := make(sdk.StructuredData, len(fields)+len(key))
+	for name, v := range fields {
+		row[name] = v
+	}
+	for name, v := range key {
+		row[name] = v
+	}
+
+	table, err := w.table(ctx, tx, tableName, key, row)
+	if err != nil {
+		return err
+	}
+	return table.upsert(ctx, tx, row)
+}
+
+// writeSnapshotRecord writes the record to the staging table of its
+// snapshot, and replaces the table with the staging table if the record is
+// the last row of the snapshot.
+func (w *sqliteWriter) writeSnapshotRecord(ctx context.Context, tx *sql.Tx, rec sdk.Record, name string, key sdk.StructuredData) error {
+	id, err := snapshotID(rec)
+	if err != nil {
+		return err
+	}
+	staging := name + sqliteStagingSuffix
+	s, ok := w.snapshots[name]
+	if !ok || s.id != id {
+		// drop the rows of a snapshot that was never completed
+		_, err = tx.ExecContext(ctx, "DROP TABLE IF EXISTS "+quoteIdent(staging))
+		if err != nil {
+			return fmt.Errorf("failed to drop table %q: %w", staging, err)
+		}
+		delete(w.tables, staging)
+		s = sqliteSnapshot{id: id, skip: rec.Metadata[metadataSnapshotRow] != "0"}
+		if s.skip {
+			sdk.Logger(ctx).Warn().Str("table", name).Msg("skipping snapshot that started before the destination")
+		}
+		w.snapshots[name] = s
+	}
+
+	if !s.skip && rec.Operation != sdk.OperationDelete {
+		err = w.upsert(ctx, tx, rec, staging, key)
+		if err != nil {
+			return err
+		}
+	}
+	if rec.Metadata[metadataSnapshotComplete] != "true" {
+		return nil
+	}
+	delete(w.snapshots, name)
+	if s.skip {
+		return nil
+	}
+	return w.swap(ctx, tx, name, staging)
+}
+
+// swap replaces the table with the staging table. Readers see the new rows
+// once the transaction is committed.
+func (w *sqliteWriter) swap(ctx context.Context, tx *sql.Tx, tableName, staging string) error {
+	table, err := w.existingTable(ctx, tx, staging)
+	if err != nil || table == nil {
+		// snapshots without rows leave the table as it is
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "DROP TABLE IF EXISTS "+quoteIdent(tableName))
+	if err != nil {
+		return fmt.Errorf("failed to drop table %q: %w", tableName, err)
+	}
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s RENAME TO %s", quoteIdent(staging), quoteIdent(tableName)))
+	if err != nil {
+		return fmt.Errorf("failed to rename table %q: %w", staging, err)
+	}
+	delete(w.tables, staging)
+	table.name = tableName
+	w.tables[tableName] = table
+	return nil
+}
+
+func (w *sqliteWriter) delete(ctx context.Context, tx *sql.Tx, tableName string, key sdk.StructuredData) error {
+	table, err := w.existingTable(ctx, tx, tableName)
+	if err != nil || table == nil {
+		return err
+	}
+	var where []string
+	var args []interface{}
+	for _, name := range table.key {
+		v, ok := key[name]
+		if !ok {
+			return fmt.Errorf("key is missing column %q of table %q", name, table.name)
+		}
+		where = append(where, quoteIdent(name)+" = ?")
+		args = append(args, sqliteValue(name, v))
+	}
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE %s", quoteIdent(table.name), strings.Join(where, " AND ")), args...)
+	return err
+}
+
+// table returns the table with the given name, creating it or adding columns
+// missing for row.
+func (w *sqliteWriter) table(ctx context.Context, tx *sql.Tx, tableName string, key, row sdk.StructuredData) (*sqliteTable, error) {
+	table, err := w.existingTable(ctx, tx, tableName)
+	if err != nil {
+		return nil, err
+	}
+	if table == nil {
+		table, err = createSQLiteTable(ctx, tx, tableName, key, row)
+		if err != nil {
+			return nil, err
+		}
+		w.tables[tableName] = table
+		return table, nil
+	}
+	for _, name := range columnOrder(row) {
+		if table.columns[name] {
+			continue
+		}
+		_, err = tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s",
+			quoteIdent(table.name), quoteIdent(name), sqliteType(name, row[name])))
+		if err != nil {
+			return nil, fmt.Errorf("failed to add column %q to table %q: %w", name, table.name, err)
+		}
+		table.columns[name] = true
+	}
+	return table, nil
+}
+
+// existingTable returns the table with the given name, or nil if it doesn't
+// exist yet.
+func (w *sqliteWriter) existingTable(ctx context.Context, tx *sql.Tx, tableName string) (*sqliteTable, error) {
+	if table, ok := w.tables[tableName]; ok {
+		return table, nil
+	}
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", quoteIdent(tableName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema of table %q: %w", tableName, err)
+	}
+	defer rows.Close()
+
+	table := &sqliteTable{name: tableName, columns: make(map[string]bool)}
+	type keyColumn struct {
+		name string
+		pos  int
+	}
+	var keyColumns []keyColumn
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, typ        string
+			dflt             sql.NullString
+		)
+		err = rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schema of table %q: %w", tableName, err)
+		}
+		table.columns[name] = true
+		if pk > 0 {
+			keyColumns = append(keyColumns, keyColumn{name: name, pos: pk})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schema of table %q: %w", tableName, err)
+	}
+	if len(table.columns) == 0 {
+		return nil, nil
+	}
+	sort.Slice(keyColumns, func(i, j int) bool { return keyColumns[i].pos < keyColumns[j].pos })
+	for _, c := range keyColumns {
+		table.key = append(table.key, c.name)
+	}
+	w.tables[tableName] = table
+	return table, nil
+}
+
+func createSQLiteTable(ctx context.Context, tx *sql.Tx, tableName string, key, row sdk.StructuredData) (*sqliteTable, error) {
+	table := &sqliteTable{name: tableName, key: columnOrder(key), columns: make(map[string]bool, len(row))}
+	var defs []string
+	for _, name := range columnOrder(row) {
+		defs = append(defs, quoteIdent(name)+" "+sqliteType(name, row[name]))
+		table.columns[name] = true
+	}
+	var keyColumns []string
+	for _, name := range table.key {
+		keyColumns = append(keyColumns, quoteIdent(name))
+	}
+	defs = append(defs, "PRIMARY KEY ("+strings.Join(keyColumns, ", ")+")")
+
+	_, err := tx.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s (%s)", quoteIdent(tableName), strings.Join(defs, ", ")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create table %q: %w", tableName, err)
+	}
+	return table, nil
+}
+
+// upsert inserts the row, or updates the row with the same key.
+func (t *sqliteTable) upsert(ctx context.Context, tx *sql.Tx, row sdk.StructuredData) error {
+	columns := columnOrder(row)
+	names := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	args := make([]interface{}, len(columns))
+	var updates []string
+	for i, name := range columns {
+		names[i] = quoteIdent(name)
+		placeholders[i] = "?"
+		args[i] = sqliteValue(name, row[name])
+		updates = append(updates, names[i]+" = excluded."+names[i])
+	}
+	keyColumns := make([]string, len(t.key))
+	for i, name := range t.key {
+		keyColumns[i] = quoteIdent(name)
+	}
+
+	_, err := tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s",
+		quoteIdent(t.name),
+		strings.Join(names, ", "),
+		strings.Join(placeholders, ", "),
+		strings.Join(keyColumns, ", "),
+		strings.Join(updates, ", "),
+	), args...)
+	return err
+}
+
+func (w *sqliteWriter) close() error {
+	return w.db.Close()
+}
+
+// sqliteKey returns the primary key columns of a record key. Raw keys are
+// stored in the column _key.
+func sqliteKey(key sdk.Data) (sdk.StructuredData, error) {
+	switch key := key.(type) {
+	case sdk.StructuredData:
+		if len(key) == 0 {
+			return nil, errors.New("record has an empty key")
+		}
+		return key, nil
+	case sdk.RawData:
+		if len(key) == 0 {
+			return nil, errors.New("record has no key")
+		}
+		return sdk.StructuredData{sqliteRawKey: string(key)}, nil
+	case nil:
+		return nil, errors.New("record has no key")
+	default:
+		return nil, fmt.Errorf("unexpected key type %T", key)
+	}
+}
+
+// sqliteType returns the declared type of a column. SQLite columns accept
+// values of any type, the declared type only sets the column affinity.
+func sqliteType(name string, v interface{}) string {
+	switch v := v.(type) {
+	case float64:
+		if strings.HasSuffix(name, "_ID") && v == math.Trunc(v) {
+			return "INTEGER"
+		}
+		return "REAL"
+	case float32:
+		return "REAL"
+	case int, int32, int64, bool:
+		return "INTEGER"
+	default:
+		return "TEXT"
+	}
+}
+
+// sqliteValue converts a field value to the value stored in its column. IDs
+// are stored as integers, nested values as JSON.
+func sqliteValue(name string, v interface{}) interface{} {
+	switch v := v.(type) {
+	case float64:
+		if strings.HasSuffix(name, "_ID") && v == math.Trunc(v) {
+			return int64(v)
+		}
+		return v
+	case map[string]interface{}, []interface{}:
+		raw, _ := json.Marshal(v)
+		return string(raw)
+	default:
+		return v
+	}
+}
+
+// quoteIdent quotes a table or column name.
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
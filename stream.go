@@ -0,0 +1,242 @@
+package nbastats
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// errResponseTooLarge is returned when a response body exceeds the configured
+// maximum response size.
+var errResponseTooLarge = errors.New("response exceeds the maximum response size")
+
+// sizeLimitedReader returns errResponseTooLarge once more than limit bytes
+// were read from the underlying reader.
+type sizeLimitedReader struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func limitResponseSize(r io.ReadCloser, limit int64) io.ReadCloser {
+	if limit <= 0 {
+		return r
+	}
+	return &sizeLimitedReader{ReadCloser: r, remaining: limit}
+}
+
+func (r *sizeLimitedReader) Read(p []byte) (int, error) {
+	if r.remaining < 0 {
+		return 0, errResponseTooLarge
+	}
+	// read one byte more than allowed to detect bodies exceeding the limit
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		// drop the extra byte, otherwise a decoder that got the complete
+		// body could finish without reading the error
+		return n - 1, errResponseTooLarge
+	}
+	return n, err
+}
+
+// resultSetDecoder walks the result sets of a stats.nba.com response and
+// returns their rows one by one, without holding the whole response in
+// memory. It understands both the "resultSets" array and the "resultSet"
+// object used by some endpoints.
+type resultSetDecoder struct {
+	dec *json.Decoder
+	url string
+	// parameters are the query parameters echoed by the response, they are
+	// available once the first row was returned.
+	parameters map[string]interface{}
+
+	started      bool
+	inResultSets bool
+	inResultSet  bool
+	inRowSet     bool
+	current      *ResultSet
+}
+
+// newResultSetDecoder returns a decoder reading the response of the given
+// URL from r.
+func newResultSetDecoder(r io.Reader, url string) *resultSetDecoder {
+	return &resultSetDecoder{dec: json.NewDecoder(r), url: url}
+}
+
+// next returns the next row and the result set it belongs to. The returned
+// result set contains the name and headers, but no rows. next returns io.EOF
+// once all rows were returned.
+func (d *resultSetDecoder) next() (*ResultSet, []interface{}, error) {
+	for {
+		switch {
+		case d.inRowSet:
+			if d.dec.More() {
+				var row []interface{}
+				err := d.dec.Decode(&row)
+				if err != nil {
+					return nil, nil, d.decodeErr(err)
+				}
+				return d.current, row, nil
+			}
+			err := d.expectDelim(']')
+			if err != nil {
+				return nil, nil, err
+			}
+			d.inRowSet = false
+
+		case d.inResultSet:
+			if !d.dec.More() {
+				err := d.expectDelim('}')
+				if err != nil {
+					return nil, nil, err
+				}
+				d.inResultSet = false
+				continue
+			}
+			key, err := d.key()
+			if err != nil {
+				return nil, nil, err
+			}
+			switch key {
+			case "name":
+				err = d.decodeErr(d.dec.Decode(&d.current.Name))
+			case "headers":
+				err = d.decodeHeaders()
+			case "rowSet":
+				err = d.expectDelim('[')
+				d.inRowSet = true
+			default:
+				err = d.skip()
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+
+		case d.inResultSets:
+			if !d.dec.More() {
+				err := d.expectDelim(']')
+				if err != nil {
+					return nil, nil, err
+				}
+				d.inResultSets = false
+				continue
+			}
+			err := d.startResultSet()
+			if err != nil {
+				return nil, nil, err
+			}
+
+		default:
+			if !d.started {
+				err := d.expectDelim('{')
+				if err != nil {
+					return nil, nil, err
+				}
+				d.started = true
+			}
+			if !d.dec.More() {
+				return nil, nil, io.EOF
+			}
+			key, err := d.key()
+			if err != nil {
+				return nil, nil, err
+			}
+			switch key {
+			case "resultSets":
+				err = d.expectDelim('[')
+				d.inResultSets = true
+			case "resultSet":
+				err = d.startResultSet()
+			case "parameters":
+				err = d.decodeParameters()
+			default:
+				err = d.skip()
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+}
+
+func (d *resultSetDecoder) startResultSet() error {
+	err := d.expectDelim('{')
+	if err != nil {
+		return err
+	}
+	d.current = &ResultSet{}
+	d.inResultSet = true
+	return nil
+}
+
+// decodeHeaders decodes the headers of the current result set. Some
+// endpoints describe their headers as objects instead of plain column names,
+// those result sets are returned without headers.
+func (d *resultSetDecoder) decodeHeaders() error {
+	var raw json.RawMessage
+	err := d.dec.Decode(&raw)
+	if err != nil {
+		return d.decodeErr(err)
+	}
+	var headers []string
+	if json.Unmarshal(raw, &headers) == nil {
+		d.current.Headers = headers
+	}
+	return nil
+}
+
+// decodeParameters decodes the parameters of the response. Some endpoints
+// return them as a list instead of an object, those are ignored.
+func (d *resultSetDecoder) decodeParameters() error {
+	var raw json.RawMessage
+	err := d.dec.Decode(&raw)
+	if err != nil {
+		return d.decodeErr(err)
+	}
+	var parameters map[string]interface{}
+	if json.Unmarshal(raw, &parameters) == nil {
+		d.parameters = parameters
+	}
+	return nil
+}
+
+func (d *resultSetDecoder) key() (string, error) {
+	tok, err := d.dec.Token()
+	if err != nil {
+		return "", d.decodeErr(err)
+	}
+	key, ok := tok.(string)
+	if !ok {
+		return "", schemaError(d.url, "expected object key, got %v", tok)
+	}
+	return key, nil
+}
+
+func (d *resultSetDecoder) expectDelim(delim json.Delim) error {
+	tok, err := d.dec.Token()
+	if err != nil {
+		return d.decodeErr(err)
+	}
+	if tok != delim {
+		return schemaError(d.url, "expected %v, got %v", delim, tok)
+	}
+	return nil
+}
+
+func (d *resultSetDecoder) skip() error {
+	var raw json.RawMessage
+	return d.decodeErr(d.dec.Decode(&raw))
+}
+
+func (d *resultSetDecoder) decodeErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
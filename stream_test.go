@@ -0,0 +1,138 @@
+package nbastats
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+// decodeAll returns all result sets read by a resultSetDecoder, with their
+// rows, and the error that stopped it.
+func decodeAll(r io.Reader) ([]ResultSet, map[string]interface{}, error) {
+	dec := newResultSetDecoder(r, "https://stats.nba.com/stats/test")
+	var sets []ResultSet
+	for {
+		rs, row, err := dec.next()
+		if err == io.EOF {
+			return sets, dec.parameters, nil
+		}
+		if err != nil {
+			return sets, dec.parameters, err
+		}
+		if len(sets) == 0 || sets[len(sets)-1].Name != rs.Name {
+			sets = append(sets, ResultSet{Name: rs.Name, Headers: rs.Headers})
+		}
+		sets[len(sets)-1].RowSet = append(sets[len(sets)-1].RowSet, row)
+	}
+}
+
+func TestResultSetDecoder(t *testing.T) {
+	testCases := []struct {
+		name       string
+		body       string
+		want       []ResultSet
+		parameters map[string]interface{}
+	}{{
+		name: "result sets",
+		body: `{"resource":"test","parameters":{"LastNGames":5},"resultSets":[
+			{"name":"A","headers":["X","Y"],"rowSet":[[1,"a"],[2,"b"]]},
+			{"name":"Empty","headers":["X"],"rowSet":[]},
+			{"name":"B","headers":["Z"],"rowSet":[[true]]}]}`,
+		want: []ResultSet{
+			{Name: "A", Headers: []string{"X", "Y"}, RowSet: [][]interface{}{{1.0, "a"}, {2.0, "b"}}},
+			{Name: "B", Headers: []string{"Z"}, RowSet: [][]interface{}{{true}}},
+		},
+		parameters: map[string]interface{}{"LastNGames": 5.0},
+	}, {
+		name: "single result set",
+		body: `{"resultSet":{"name":"A","headers":["X"],"rowSet":[[1]]},"parameters":[]}`,
+		want: []ResultSet{{Name: "A", Headers: []string{"X"}, RowSet: [][]interface{}{{1.0}}}},
+	}, {
+		name: "header objects",
+		body: `{"resultSets":[{"name":"A","headers":[{"name":"X"}],"unknown":{"a":[1]},"rowSet":[[1]]}]}`,
+		want: []ResultSet{{Name: "A", RowSet: [][]interface{}{{1.0}}}},
+	}, {
+		name: "no result sets",
+		body: `{"resource":"test"}`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			sets, parameters, err := decodeAll(strings.NewReader(tc.body))
+			is.NoErr(err)
+			is.Equal(sets, tc.want)
+			is.Equal(parameters, tc.parameters)
+		})
+	}
+}
+
+func TestResultSetDecoder_Malformed(t *testing.T) {
+	testCases := []struct {
+		name   string
+		body   string
+		schema bool
+	}{
+		{name: "empty", body: ``},
+		{name: "not an object", body: `[1,2]`, schema: true},
+		{name: "result sets not an array", body: `{"resultSets":{"name":"A"}}`, schema: true},
+		{name: "result set not an object", body: `{"resultSets":[1]}`, schema: true},
+		{name: "row set not an array", body: `{"resultSets":[{"rowSet":{}}]}`, schema: true},
+		{name: "row not an array", body: `{"resultSets":[{"rowSet":[{"a":1}]}]}`},
+		{name: "truncated", body: `{"resultSets":[{"name":"A","rowSet":[[1],[2`},
+		{name: "invalid json", body: `{"resultSets":[{"name":"A","rowSet":[[1],x]}]}`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			_, _, err := decodeAll(strings.NewReader(tc.body))
+			is.True(err != nil)
+			is.Equal(errors.Is(err, errSchemaMismatch), tc.schema)
+		})
+	}
+}
+
+func TestResultSetDecoder_TooLarge(t *testing.T) {
+	is := is.New(t)
+	body := `{"resultSets":[{"name":"A","headers":["X"],"rowSet":[[1],[2],[3]]}]}`
+
+	sets, _, err := decodeAll(limitResponseSize(io.NopCloser(strings.NewReader(body)), int64(len(body))))
+	is.NoErr(err)
+	is.Equal(len(sets[0].RowSet), 3)
+
+	_, _, err = decodeAll(limitResponseSize(io.NopCloser(strings.NewReader(body)), int64(len(body)-1)))
+	is.True(errors.Is(err, errResponseTooLarge))
+}
+
+func TestSizeLimitedReader(t *testing.T) {
+	testCases := []struct {
+		name  string
+		size  int
+		limit int64
+		err   error
+	}{
+		{name: "no limit", size: 100, limit: 0},
+		{name: "below limit", size: 99, limit: 100},
+		{name: "at limit", size: 100, limit: 100},
+		{name: "above limit", size: 101, limit: 100, err: errResponseTooLarge},
+		{name: "far above limit", size: 10000, limit: 100, err: errResponseTooLarge},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			r := limitResponseSize(io.NopCloser(strings.NewReader(strings.Repeat("x", tc.size))), tc.limit)
+			data, err := io.ReadAll(r)
+			is.Equal(err, tc.err)
+			if tc.err == nil {
+				is.Equal(len(data), tc.size)
+			} else {
+				is.Equal(int64(len(data)), tc.limit)
+				// the error is sticky
+				_, err = r.Read(make([]byte, 10))
+				is.Equal(err, errResponseTooLarge)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package nbastats
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// ProxyConfig configures the proxy requests to stats.nba.com are sent
+// through.
+type ProxyConfig struct {
+	// URL of the proxy, the scheme must be http, https or socks5. If empty,
+	// the proxy is taken from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	// environment variables.
+	URL string `json:"url"`
+	// Username used to authenticate with the proxy.
+	Username string `json:"username"`
+	// Password used to authenticate with the proxy.
+	Password string `json:"password"`
+}
+
+// TLSConfig configures the TLS connections to stats.nba.com (or to an https
+// proxy).
+type TLSConfig struct {
+	// CAFile is the path to a PEM encoded CA bundle trusted in addition to
+	// the system certificate pool.
+	CAFile string `json:"caFile"`
+	// CertFile is the path to a PEM encoded client certificate.
+	CertFile string `json:"certFile"`
+	// KeyFile is the path to the PEM encoded private key of the client
+	// certificate.
+	KeyFile string `json:"keyFile"`
+}
+
+// proxyFunc returns the function the transport uses to select a proxy.
+func (c ProxyConfig) proxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	if c.URL == "" {
+		if c.Username != "" || c.Password != "" {
+			return nil, fmt.Errorf("proxy credentials require a proxy url")
+		}
+		return http.ProxyFromEnvironment, nil
+	}
+
+	proxyURL, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url: %w", err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q, expected http, https or socks5", proxyURL.Scheme)
+	}
+	if c.Username != "" {
+		proxyURL.User = url.UserPassword(c.Username, c.Password)
+	}
+	return http.ProxyURL(proxyURL), nil
+}
+
+// tlsConfig returns the TLS configuration of the transport, or nil if the
+// defaults should be used.
+func (c TLSConfig) tlsConfig() (*tls.Config, error) {
+	if c.CAFile == "" && c.CertFile == "" && c.KeyFile == "" {
+		return nil, nil
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if c.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %q", c.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		if c.CertFile == "" || c.KeyFile == "" {
+			return nil, fmt.Errorf("a client certificate requires both a certificate and a key file")
+		}
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
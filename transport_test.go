@@ -0,0 +1,52 @@
+package nbastats
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestStatsClient_TLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"resultSets":[]}`)
+	}))
+	defer srv.Close()
+	// the server certificate is self-signed, it's its own CA
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name   string
+		caFile string
+		wantOK bool
+	}{
+		{"custom ca", caFile, true},
+		{"unknown certificate", "", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			c := newServerClient(t, srv, HTTPConfig{TLS: TLSConfig{CAFile: tc.caFile}})
+			body, err := c.openNBAStats(context.Background(), buildNBAStatsURL(NewNBAStatsQueryParams()))
+			if !tc.wantOK {
+				var unknownAuthority x509.UnknownAuthorityError
+				is.True(errors.As(err, &unknownAuthority))
+				return
+			}
+			is.NoErr(err)
+			is.NoErr(body.Close())
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package nbastats
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+const (
+	// webhookTimestampHeader is the header containing the Unix time the
+	// request was signed at.
+	webhookTimestampHeader = "X-NBA-Stats-Timestamp"
+	// webhookSignatureHeader is the header containing the HMAC-SHA256 of the
+	// timestamp and the body, formatted as "sha256=<hex>".
+	webhookSignatureHeader = "X-NBA-Stats-Signature"
+	// webhookDeliveryHeader is the header identifying the batch of records,
+	// it stays the same when a request is retried.
+	webhookDeliveryHeader = "X-NBA-Stats-Delivery"
+)
+
+// WebhookConfig configures the requests sent with format webhook.
+type WebhookConfig struct {
+	// URL is the URL the records are posted to.
+	URL string `json:"url"`
+	// Secret is the key requests are signed with, empty disables signing.
+	Secret string `json:"secret"`
+	// MaxRecords is the maximum number of records posted in one request,
+	// larger batches are split into several requests.
+	MaxRecords int `json:"maxRecords" default:"100"`
+	// Timeout is the maximum time a single request may take.
+	Timeout time.Duration `json:"timeout" default:"10s"`
+	// MaxRetries is the number of times a failed request is retried before
+	// the write fails.
+	MaxRetries int `json:"maxRetries" default:"5"`
+	// RetryBackoff is the delay before the first retry, it is doubled with
+	// every further retry.
+	RetryBackoff time.Duration `json:"retryBackoff" default:"1s"`
+	// MaxRetryBackoff is the maximum delay between retries.
+	MaxRetryBackoff time.Duration `json:"maxRetryBackoff" default:"1m"`
+}
+
+// validate checks the webhook parameters.
+func (c WebhookConfig) validate() error {
+	if c.URL == "" {
+		return errors.New("format webhook requires a webhook URL")
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook URL %q, expected an http or https URL", c.URL)
+	}
+	if c.MaxRecords < 1 {
+		return fmt.Errorf("invalid webhook max records %d, expected at least 1", c.MaxRecords)
+	}
+	if c.MaxRetries < 0 {
+		return fmt.Errorf("invalid webhook max retries %d", c.MaxRetries)
+	}
+	return nil
+}
+
+// webhookRequest is the body of a webhook request.
+type webhookRequest struct {
+	Records []webhookRecord `json:"records"`
+}
+
+// webhookRecord is a record in the body of a webhook request. Payload is
+// omitted for deletes.
+type webhookRecord struct {
+	Operation  string             `json:"operation"`
+	Collection string             `json:"collection"`
+	Key        interface{}        `json:"key,omitempty"`
+	Metadata   sdk.Metadata       `json:"metadata"`
+	Payload    sdk.StructuredData `json:"payload,omitempty"`
+}
+
+// webhookWriter posts records as JSON to a URL, in requests of at most
+// MaxRecords records. Failed requests are retried with an exponential
+// backoff. If a request still fails, the records of the previous requests
+// are reported as written, so the SDK only retries the rest.
+type webhookWriter struct {
+	config WebhookConfig
+	client *http.Client
+}
+
+func newWebhookWriter(config WebhookConfig) *webhookWriter {
+	return &webhookWriter{config: config, client: &http.Client{Timeout: config.Timeout}}
+}
+
+func (w *webhookWriter) write(ctx context.Context, records []sdk.Record) (int, error) {
+	for start := 0; start < len(records); start += w.config.MaxRecords {
+		end := start + w.config.MaxRecords
+		if end > len(records) {
+			end = len(records)
+		}
+		batch := make([]webhookRecord, 0, end-start)
+		var recErr error
+		for i, rec := range records[start:end] {
+			r, err := newWebhookRecord(rec)
+			if err != nil {
+				recErr = fmt.Errorf("invalid record %d: %w", start+i, err)
+				break
+			}
+			batch = append(batch, r)
+		}
+		// the valid records before an invalid one are still delivered
+		if len(batch) > 0 {
+			err := w.post(ctx, batch)
+			if err != nil {
+				return start, err
+			}
+		}
+		if recErr != nil {
+			return start + len(batch), recErr
+		}
+	}
+	return len(records), nil
+}
+
+func newWebhookRecord(rec sdk.Record) (webhookRecord, error) {
+	r := webhookRecord{
+		Operation:  rec.Operation.String(),
+		Collection: recordCollection(rec),
+		Metadata:   rec.Metadata,
+	}
+	switch key := rec.Key.(type) {
+	case sdk.StructuredData:
+		r.Key = key
+	case sdk.RawData:
+		if len(key) > 0 {
+			r.Key = string(key)
+		}
+	}
+	if rec.Operation == sdk.OperationDelete {
+		return r, nil
+	}
+	fields, err := recordFields(rec)
+	if err != nil {
+		return webhookRecord{}, err
+	}
+	r.Payload = fields
+	return r, nil
+}
+
+// post posts the records, retrying failed requests.
+func (w *webhookWriter) post(ctx context.Context, records []webhookRecord) error {
+	body, err := json.Marshal(webhookRequest{Records: records})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook request: %w", err)
+	}
+	sum := sha256.Sum256(body)
+	delivery := hex.EncodeToString(sum[:16])
+
+	backoff := w.config.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := w.send(ctx, body, delivery)
+		if err == nil {
+			return nil
+		}
+		if !isRetryable(err) || attempt == w.config.MaxRetries {
+			return fmt.Errorf("failed to post %d records: %w", len(records), err)
+		}
+
+		delay := backoff
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		if w.config.MaxRetryBackoff > 0 && delay > w.config.MaxRetryBackoff {
+			delay = w.config.MaxRetryBackoff
+		}
+		sdk.Logger(ctx).Warn().Err(err).Dur("backoff", delay).Int("attempt", attempt+1).Msg("failed to post records, retrying")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		backoff *= 2
+	}
+}
+
+// send sends one request. It returns the delay requested by the
+// Retry-After header of a failed response, 0 if there is none.
+func (w *webhookWriter) send(ctx context.Context, body []byte, delivery string) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookDeliveryHeader, delivery)
+	if w.config.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(webhookTimestampHeader, timestamp)
+		req.Header.Set(webhookSignatureHeader, "sha256="+webhookSignature(w.config.Secret, timestamp, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, transportError(ctx, w.config.URL, err)
+	}
+	defer resp.Body.Close()
+	// drain the body, so that the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return 0, nil
+	}
+	return retryAfter(resp), statusError(w.config.URL, resp)
+}
+
+// webhookSignature returns the hex encoded HMAC-SHA256 of the timestamp and
+// the body, joined by a dot, with the secret as key. Receivers should reject
+// requests with old timestamps, so that requests can't be replayed.
+func webhookSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryAfter returns the delay requested by the Retry-After header of the
+// response, given in seconds or as HTTP date, 0 if there is none.
+func retryAfter(resp *http.Response) time.Duration {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+func (w *webhookWriter) close() error {
+	w.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,108 @@
+package nbastats
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// metadataWindow is the record metadata key containing the name of the
+// window the stats in the record were aggregated over (e.g. "last10").
+const metadataWindow = "nbastats.window"
+
+// windowSeason is the window covering the whole season.
+const windowSeason = "season"
+
+// windowDateFormat is the format of the DateFrom and DateTo parameters.
+const windowDateFormat = "01/02/2006"
+
+var windowFormat = regexp.MustCompile(`^last(\d+)(d?)$`)
+
+// statsWindow is a range of games the stats are aggregated over. A window
+// covers either the whole season, the last N games (LastNGames) or the last
+// N days (DateFrom/DateTo).
+type statsWindow struct {
+	Name       string
+	LastNGames int
+	Days       int
+}
+
+// parseWindows parses window names formatted as "season", "last<N>" for the
+// last N games or "last<N>d" for the last N days. No names results in the
+// season window.
+func parseWindows(names []string) ([]statsWindow, error) {
+	if len(names) == 0 {
+		return []statsWindow{{Name: windowSeason}}, nil
+	}
+	windows := make([]statsWindow, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[name] {
+			return nil, fmt.Errorf("window %q is configured twice", name)
+		}
+		seen[name] = true
+
+		if name == windowSeason {
+			windows = append(windows, statsWindow{Name: name})
+			continue
+		}
+		match := windowFormat.FindStringSubmatch(name)
+		if match == nil {
+			return nil, fmt.Errorf("invalid window %q, expected %q, \"last<N>\" or \"last<N>d\"", name, windowSeason)
+		}
+		n, err := strconv.Atoi(match[1])
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid window %q, the window size must be greater than 0", name)
+		}
+		if match[2] == "d" {
+			windows = append(windows, statsWindow{Name: name, Days: n})
+		} else {
+			windows = append(windows, statsWindow{Name: name, LastNGames: n})
+		}
+	}
+	return windows, nil
+}
+
+// apply returns a copy of params restricted to the window, date windows
+// cover N calendar days ending on the day of now. DateFrom and DateTo are
+// both inclusive.
+func (w statsWindow) apply(params NBAStatsQueryParams, now time.Time) NBAStatsQueryParams {
+	switch {
+	case w.LastNGames > 0:
+		params.LastNGames = w.LastNGames
+	case w.Days > 0:
+		params.DateFrom = now.AddDate(0, 0, 1-w.Days).Format(windowDateFormat)
+		params.DateTo = now.Format(windowDateFormat)
+	}
+	return params
+}
+
+// windowFromParameters returns the name of the window a response was
+// requested for, based on the parameters it echoes. Day windows are derived
+// from the number of days between DateFrom and DateTo.
+func windowFromParameters(parameters map[string]interface{}) string {
+	if n, ok := toInt(parameters["LastNGames"]); ok && n > 0 {
+		return fmt.Sprintf("last%d", n)
+	}
+	from, _ := parameters["DateFrom"].(string)
+	to, _ := parameters["DateTo"].(string)
+	if days, ok := windowDays(from, to); ok {
+		return fmt.Sprintf("last%dd", days)
+	}
+	return windowSeason
+}
+
+// windowDays returns the number of days between the DateFrom and DateTo
+// parameters of a day window, both inclusive.
+func windowDays(from, to string) (int, bool) {
+	if from == "" || to == "" {
+		return 0, false
+	}
+	fromDate, errFrom := time.Parse(windowDateFormat, from)
+	toDate, errTo := time.Parse(windowDateFormat, to)
+	if errFrom != nil || errTo != nil || toDate.Before(fromDate) {
+		return 0, false
+	}
+	return int(toDate.Sub(fromDate).Hours()/24) + 1, true
+}
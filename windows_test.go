@@ -0,0 +1,77 @@
+package nbastats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestStatsWindow_Apply(t *testing.T) {
+	now := time.Date(2024, time.March, 3, 22, 30, 0, 0, time.UTC)
+	testCases := []struct {
+		window     string
+		lastNGames int
+		dateFrom   string
+		dateTo     string
+	}{
+		{window: "season"},
+		{window: "last10", lastNGames: 10},
+		{window: "last1d", dateFrom: "03/03/2024", dateTo: "03/03/2024"},
+		{window: "last7d", dateFrom: "02/26/2024", dateTo: "03/03/2024"},
+		{window: "last30d", dateFrom: "02/03/2024", dateTo: "03/03/2024"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.window, func(t *testing.T) {
+			is := is.New(t)
+			windows, err := parseWindows([]string{tc.window})
+			is.NoErr(err)
+
+			params := windows[0].apply(NBAStatsQueryParams{Season: "2023-24"}, now)
+			is.Equal(params.Season, "2023-24")
+			is.Equal(params.LastNGames, tc.lastNGames)
+			is.Equal(params.DateFrom, tc.dateFrom)
+			is.Equal(params.DateTo, tc.dateTo)
+		})
+	}
+}
+
+func TestWindowFromParameters(t *testing.T) {
+	testCases := []struct {
+		name       string
+		parameters map[string]interface{}
+		want       string
+	}{
+		{"no parameters", nil, "season"},
+		{"season", map[string]interface{}{"LastNGames": 0.0, "DateFrom": nil, "DateTo": nil}, "season"},
+		{"last games", map[string]interface{}{"LastNGames": 10.0}, "last10"},
+		{"last day", map[string]interface{}{"DateFrom": "03/03/2024", "DateTo": "03/03/2024"}, "last1d"},
+		{"last days", map[string]interface{}{"LastNGames": 0.0, "DateFrom": "02/26/2024", "DateTo": "03/03/2024"}, "last7d"},
+		{"month", map[string]interface{}{"DateFrom": "03/01/2024", "DateTo": "03/30/2024"}, "last30d"},
+		{"open range", map[string]interface{}{"DateFrom": "02/26/2024", "DateTo": ""}, "season"},
+		{"reversed range", map[string]interface{}{"DateFrom": "03/03/2024", "DateTo": "02/26/2024"}, "season"},
+		{"invalid date", map[string]interface{}{"DateFrom": "2024-02-26", "DateTo": "2024-03-03"}, "season"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(windowFromParameters(tc.parameters), tc.want)
+		})
+	}
+}
+
+func TestWindowFromParameters_Apply(t *testing.T) {
+	is := is.New(t)
+	now := time.Date(2024, time.March, 3, 22, 30, 0, 0, time.UTC)
+	windows, err := parseWindows([]string{"season", "last5", "last7d", "last30d"})
+	is.NoErr(err)
+	for _, w := range windows {
+		params := w.apply(NBAStatsQueryParams{}, now)
+		parameters := map[string]interface{}{
+			"LastNGames": float64(params.LastNGames),
+			"DateFrom":   params.DateFrom,
+			"DateTo":     params.DateTo,
+		}
+		is.Equal(windowFromParameters(parameters), w.Name)
+	}
+}
@@ -0,0 +1,132 @@
+package nbastats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// recordWriter writes records to the output of the destination.
+type recordWriter interface {
+	// write writes the records and returns the number of records written.
+	// It returns an error if not all records were written.
+	write(ctx context.Context, records []sdk.Record) (int, error)
+	// close flushes all buffered records and releases the output.
+	close() error
+}
+
+// idColumns are identifying columns, they are placed first when the columns
+// of a table are derived from a record.
+var idColumns = []string{"PLAYER_ID", "PLAYER_NAME", "TEAM_ID", "TEAM_ABBREVIATION"}
+
+// recordFields returns the fields of the row in the record payload. Raw
+// payloads have to contain a JSON object. Records without a payload, like
+// deletes, return nil.
+func recordFields(rec sdk.Record) (sdk.StructuredData, error) {
+	switch data := rec.Payload.After.(type) {
+	case nil:
+		return nil, nil
+	case sdk.StructuredData:
+		return data, nil
+	case sdk.RawData:
+		if len(data) == 0 {
+			return nil, nil
+		}
+		var fields sdk.StructuredData
+		err := json.Unmarshal(data, &fields)
+		if err != nil {
+			return nil, fmt.Errorf("payload is not a JSON object: %w", err)
+		}
+		if _, ok := fields["resultSets"]; ok {
+			return nil, fmt.Errorf("payload is a whole stats.nba.com response, use the source format rows")
+		}
+		return fields, nil
+	default:
+		return nil, fmt.Errorf("unexpected payload type %T", data)
+	}
+}
+
+// recordKey returns the key of the record as a string identifying its row,
+// structured keys are encoded as JSON.
+func recordKey(rec sdk.Record) (string, error) {
+	if rec.Key == nil || len(rec.Key.Bytes()) == 0 {
+		return "", errors.New("record has no key")
+	}
+	return string(rec.Key.Bytes()), nil
+}
+
+// columnOrder returns the columns of fields in the order they are written:
+// the ID columns first, then all other columns sorted by name.
+func columnOrder(fields sdk.StructuredData) []string {
+	columns := make([]string, 0, len(fields))
+	for _, id := range idColumns {
+		if _, ok := fields[id]; ok {
+			columns = append(columns, id)
+		}
+	}
+	var rest []string
+	for name := range fields {
+		if !isIDColumn(name) {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	return append(columns, rest...)
+}
+
+func isIDColumn(name string) bool {
+	for _, id := range idColumns {
+		if id == name {
+			return true
+		}
+	}
+	return false
+}
+
+// numericValue returns the value of a numeric field as float64.
+func numericValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// recordCollection returns the collection of a record, falling back to
+// "records" for records that don't belong to one.
+func recordCollection(rec sdk.Record) string {
+	if collection := rec.Metadata[metadataCollection]; collection != "" {
+		return collection
+	}
+	return "records"
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// fileName turns the given parts into a file name, replacing characters
+// that aren't safe in file names.
+func fileName(parts ...string) string {
+	var nonEmpty []string
+	for _, p := range parts {
+		if p = unsafeFileChars.ReplaceAllString(p, "-"); p != "" {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+	return strings.Join(nonEmpty, "_")
+}